@@ -19,7 +19,7 @@ type Helper struct {
 }
 
 func (inst *Helper) Dev() {
-	kiruna.SetModeToDev()
+	inst.Kiruna.SetModeToDev()
 	inst.mustCommonBuild(true)
 	inst.Kiruna.MustStartDev(inst.DevConfig)
 }
@@ -42,7 +42,7 @@ func (inst *Helper) ProdBuildNonGo() {
 
 func (inst *Helper) Gen(isDev bool) {
 	if isDev {
-		kiruna.SetModeToDev()
+		inst.Kiruna.SetModeToDev()
 	}
 	if inst.GenHook == nil {
 		panic("kiruna: buildhelper: GenHook is nil")
@@ -3,6 +3,7 @@ module github.com/sjc5/kiruna
 go 1.22.0
 
 require (
+	github.com/andybalholm/brotli v1.1.1
 	github.com/bmatcuk/doublestar/v4 v4.8.1
 	github.com/evanw/esbuild v0.25.1
 	github.com/fsnotify/fsnotify v1.8.0
@@ -0,0 +1,237 @@
+package ik
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// The dev server tells the running app about changes it can handle without
+// a restart over this WebSocket. The app acks each event once its callbacks
+// have run, so the browser is only reloaded after caches have been dropped.
+
+const (
+	devAppEventsPath              = "/__kiruna/app-events"
+	appEventPrivateFilesChanged   = "private-files-changed"
+	appEventAck                   = "ack"
+	appEventAckTimeout            = 5 * time.Second
+	appEventsReconnectInterval    = 250 * time.Millisecond
+	appEventsMaxReconnectAttempts = 40
+)
+
+type appEvent struct {
+	Type string `json:"type"`
+	ID   uint64 `json:"id,omitempty"` // Echoed back in the ack
+}
+
+type appEventsHub struct {
+	mu     sync.Mutex
+	conns  map[*appEventsConn]struct{}
+	nextID atomic.Uint64
+
+	// Set by the dev server
+	isAllowedOrigin func(origin string) bool
+}
+
+// appEventsConn is a connected app. Its read pump is the only reader, so
+// that closed connections are noticed (and dropped) as soon as they close.
+type appEventsConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	acks    chan uint64   // IDs of acked events
+	closed  chan struct{} // Closed once the read pump exits
+}
+
+func newAppEventsHub() *appEventsHub {
+	return &appEventsHub{conns: make(map[*appEventsConn]struct{})}
+}
+
+func (hub *appEventsHub) handler(w http.ResponseWriter, r *http.Request) {
+	if !originAllowed(hub.isAllowedOrigin, r.Header.Get("Origin")) {
+		http.Error(w, "forbidden origin", http.StatusForbidden)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	ac := &appEventsConn{conn: conn, acks: make(chan uint64, 8), closed: make(chan struct{})}
+	hub.mu.Lock()
+	hub.conns[ac] = struct{}{}
+	hub.mu.Unlock()
+
+	go hub.readPump(ac)
+}
+
+func (hub *appEventsHub) readPump(ac *appEventsConn) {
+	defer func() {
+		hub.mu.Lock()
+		delete(hub.conns, ac)
+		hub.mu.Unlock()
+		ac.conn.Close()
+		close(ac.closed)
+	}()
+	for {
+		var evt appEvent
+		if err := ac.conn.ReadJSON(&evt); err != nil {
+			return
+		}
+		if evt.Type != appEventAck {
+			continue
+		}
+		select {
+		case ac.acks <- evt.ID:
+		default: // Nobody is waiting for it anymore
+		}
+	}
+}
+
+// notify sends evtType to every connected app concurrently, and waits for
+// each to ack. Apps that fail to ack in time are dropped.
+func (hub *appEventsHub) notify(evtType string) int {
+	hub.mu.Lock()
+	conns := make([]*appEventsConn, 0, len(hub.conns))
+	for ac := range hub.conns {
+		conns = append(conns, ac)
+	}
+	hub.mu.Unlock()
+
+	evt := appEvent{Type: evtType, ID: hub.nextID.Add(1)}
+
+	var acked atomic.Int64
+	var wg sync.WaitGroup
+	for _, ac := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ac.notify(evt) {
+				acked.Add(1)
+			} else {
+				ac.conn.Close() // Its read pump takes it out of the hub
+			}
+		}()
+	}
+	wg.Wait()
+	return int(acked.Load())
+}
+
+func (ac *appEventsConn) notify(evt appEvent) bool {
+	ac.writeMu.Lock()
+	ac.conn.SetWriteDeadline(time.Now().Add(appEventAckTimeout))
+	err := ac.conn.WriteJSON(evt)
+	ac.writeMu.Unlock()
+	if err != nil {
+		return false
+	}
+
+	timeout := time.After(appEventAckTimeout)
+	for {
+		select {
+		case id := <-ac.acks:
+			if id == evt.ID {
+				return true
+			}
+			// A late ack for an event that already timed out
+		case <-ac.closed:
+			return false
+		case <-timeout:
+			return false
+		}
+	}
+}
+
+func (hub *appEventsHub) closeAll() {
+	hub.mu.Lock()
+	conns := make([]*appEventsConn, 0, len(hub.conns))
+	for ac := range hub.conns {
+		conns = append(conns, ac)
+	}
+	hub.mu.Unlock()
+	for _, ac := range conns {
+		ac.conn.Close()
+		<-ac.closed
+	}
+}
+
+// getAppEventsURL returns the URL the app connects to for app events, which
+// is served by whichever HTTP server the dev server runs.
+func (c *Config) getAppEventsURL() string {
+	switch {
+	case c.ServerOnly:
+		return ""
+	case c.useDevProxy:
+		return "ws://localhost:" + strconv.Itoa(c.port) + devAppEventsPath
+	default:
+		return "ws://localhost:" + strconv.Itoa(c.refreshServerPort) + devAppEventsPath
+	}
+}
+
+func (c *Config) notifyAppOfPrivateFilesChangeDev() {
+	acked := c.appEvents.notify(appEventPrivateFilesChanged)
+	c.Logger.Info("Notified app of private file changes", "acked", acked)
+}
+
+// OnPrivateFilesChangeDev registers fn to run in your app whenever the dev
+// server hot reloads private static files (see DevConfig.HotReloadPrivateFiles),
+// before the browser is reloaded. Use it to drop anything you cache from the
+// private FS yourself. Kiruna's own caches are always bypassed in dev. No-op
+// outside of dev.
+func (c *Config) OnPrivateFilesChangeDev(fn func()) {
+	if !c.getIsDev() {
+		return
+	}
+	c.appEventCallbacks.mu.Lock()
+	c.appEventCallbacks.v = append(c.appEventCallbacks.v, fn)
+	c.appEventCallbacks.mu.Unlock()
+
+	c.appEventsListenOnce.Do(func() {
+		go c.listenForAppEventsDev(os.Getenv(appEventsURLKey))
+	})
+}
+
+// listenForAppEventsDev runs in the app process for as long as it lives
+// (the dev server restarts the app rather than the other way around).
+func (c *Config) listenForAppEventsDev(url string) {
+	if url == "" {
+		return
+	}
+
+	var conn *websocket.Conn
+	for attempt := 0; ; attempt++ {
+		var err error
+		conn, _, err = websocket.DefaultDialer.Dial(url, nil)
+		if err == nil {
+			break
+		}
+		if attempt >= appEventsMaxReconnectAttempts {
+			c.Logger.Error(fmt.Sprintf("error connecting to dev server for app events: %v", err))
+			return
+		}
+		time.Sleep(appEventsReconnectInterval)
+	}
+	defer conn.Close()
+
+	for {
+		var evt appEvent
+		if err := conn.ReadJSON(&evt); err != nil {
+			return
+		}
+		if evt.Type == appEventPrivateFilesChanged {
+			c.appEventCallbacks.mu.Lock()
+			callbacks := append([]func(){}, c.appEventCallbacks.v...)
+			c.appEventCallbacks.mu.Unlock()
+			for _, fn := range callbacks {
+				fn()
+			}
+		}
+		if err := conn.WriteJSON(appEvent{Type: appEventAck, ID: evt.ID}); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package ik
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestAppEventsPrivateFilesChange(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+	defer resetEnv()
+
+	hub := newAppEventsHub()
+	server := httptest.NewServer(http.HandlerFunc(hub.handler))
+	defer server.Close()
+	defer hub.closeAll()
+
+	// Acts as the app process
+	app := &Config{Logger: env.config.Logger}
+	app.setModeToDev()
+	os.Setenv(appEventsURLKey, "ws"+strings.TrimPrefix(server.URL, "http")+devAppEventsPath)
+
+	var calls atomic.Int32
+	app.OnPrivateFilesChangeDev(func() { calls.Add(1) })
+
+	waitForConns := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			hub.mu.Lock()
+			connected := len(hub.conns)
+			hub.mu.Unlock()
+			if connected == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("app events connections = %d, want %d", connected, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForConns(1)
+
+	// The callback must have run by the time notify returns
+	if acked := hub.notify(appEventPrivateFilesChanged); acked != 1 || calls.Load() != 1 {
+		t.Errorf("notify() acked = %d, calls = %d, want 1 and 1", acked, calls.Load())
+	}
+
+	// A connection left over from a previous app process is dropped as soon
+	// as it closes, rather than stalling the next notify
+	stale, _, err := websocket.DefaultDialer.Dial(os.Getenv(appEventsURLKey), nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	waitForConns(2)
+	stale.Close()
+	waitForConns(1)
+
+	start := time.Now()
+	if acked := hub.notify(appEventPrivateFilesChanged); acked != 1 || calls.Load() != 2 {
+		t.Errorf("notify() acked = %d, calls = %d, want 1 and 2", acked, calls.Load())
+	}
+	if elapsed := time.Since(start); elapsed >= appEventAckTimeout {
+		t.Errorf("notify() took %v", elapsed)
+	}
+
+	// Outside of dev, registering is a no-op
+	os.Unsetenv(modeKey)
+	prodApp := &Config{Logger: env.config.Logger}
+	prodApp.OnPrivateFilesChangeDev(func() {})
+	if len(prodApp.appEventCallbacks.v) != 0 {
+		t.Errorf("OnPrivateFilesChangeDev() registered a callback outside of dev")
+	}
+}
+
+func TestHotReloadPrivateFiles(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "private-static/templates/home.html", "v1")
+	env.config.Templates = TemplatesConfig{Glob: "templates/*.html"}
+
+	if err := env.config.SetupDistDir(); err != nil {
+		t.Fatalf("SetupDistDir() error = %v", err)
+	}
+	if err := env.config.copyPrivateFiles(false); err != nil {
+		t.Fatalf("copyPrivateFiles() error = %v", err)
+	}
+
+	env.createTestFile(t, "private-static/templates/home.html", "v2")
+	if err := env.config.hotReloadPrivateFiles(); err != nil {
+		t.Fatalf("hotReloadPrivateFiles() error = %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(testRootDir, "dist/kiruna/static/private/templates/home.html"))
+	if err != nil || string(content) != "v2" {
+		t.Errorf("private file after hot reload = %q (err: %v), want v2", content, err)
+	}
+
+	env.createTestFile(t, "private-static/templates/home.html", "{{if}}")
+	if err := env.config.hotReloadPrivateFiles(); err == nil {
+		t.Errorf("hotReloadPrivateFiles() with broken template error = nil, want error")
+	}
+}
+
+func TestHotReloadPrivateFilesServerOnly(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	// No server hosts the app events hub in ServerOnly mode, so private
+	// files must keep restarting the app
+	for _, serverOnly := range []bool{false, true} {
+		env.config.ServerOnly = serverOnly
+		env.config.devConfig = &DevConfig{HotReloadPrivateFiles: true}
+		env.config.cleanWatchRoot = "."
+		if err := env.config.devInit(); err != nil {
+			t.Fatalf("devInit() error = %v", err)
+		}
+		env.config.watcher.Close()
+
+		private := (*env.config.defaultWatchedFiles)[0]
+		if private.RestartApp != serverOnly || private.hotReloadsPrivateFiles == serverOnly {
+			t.Errorf("ServerOnly = %v: RestartApp = %v, hotReloadsPrivateFiles = %v", serverOnly, private.RestartApp, private.hotReloadsPrivateFiles)
+		}
+	}
+}
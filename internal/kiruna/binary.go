@@ -1,22 +1,83 @@
 package ik
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"time"
 )
 
+// compileBinary compiles the app binary and, outside of dev, every binary in
+// Config.GoBinaries too (in dev, those are compiled as needed by the dev loop).
 func (c *Config) compileBinary() error {
-	buildDest := c.__dist.S().Bin.S().Main.FullPath()
-	buildCmd := exec.Command("go", "build", "-o", buildDest, c.MainAppEntry)
+	settings := c.getGoBuildSettings()
+	report := settings.toReport()
+
+	if err := c.compileGoBinary("", c.MainAppEntry, report); err != nil {
+		return err
+	}
+	if !c.getIsDev() {
+		for _, b := range c.GoBinaries {
+			if err := c.compileGoBinary(b.Name, b.Entry, report); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.buildReportMu.Lock()
+	c.goBuildReport = report
+	c.buildReportMu.Unlock()
+	return nil
+}
+
+// compileGoBinary compiles entry for every configured target (or just the
+// host), and records the outputs in report, if not nil.
+func (c *Config) compileGoBinary(binaryName, entry string, report *BuildGoReport) error {
+	settings := c.getGoBuildSettings()
+
+	targets := c.getGoBuildTargets()
+	if len(targets) == 0 {
+		return c.compileGoBinaryForTarget(settings, binaryName, entry, nil, report)
+	}
+	for i := range targets {
+		if err := c.compileGoBinaryForTarget(settings, binaryName, entry, &targets[i], report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) compileGoBinaryForTarget(
+	settings *GoBuildSettings, binaryName, entry string, target *GoBuildTarget, report *BuildGoReport,
+) error {
+	buildDest := c.getGoBinaryPath(binaryName, target)
+	buildCmd := exec.Command("go", settings.getBuildArgs(buildDest, entry)...)
+	buildCmd.Env = settings.getEnv(target)
+	var output bytes.Buffer
 	buildCmd.Stdout = os.Stdout
-	buildCmd.Stderr = os.Stderr
+	buildCmd.Stderr = io.MultiWriter(os.Stderr, &output)
 	a := time.Now()
 	err := buildCmd.Run()
 	if err != nil {
-		return fmt.Errorf("error compiling binary: %v", err)
+		return &goCompileError{output: output.String(), err: err}
+	}
+
+	if report != nil {
+		binary := BuildGoBinary{Name: c.getGoBinaryName(binaryName), Path: filepath.Base(buildDest)}
+		if target != nil {
+			binary.GOOS, binary.GOARCH = target.GOOS, target.GOARCH
+		}
+		info, err := os.Stat(buildDest)
+		if err != nil {
+			return fmt.Errorf("error reading compiled binary: %v", err)
+		}
+		binary.Size = info.Size()
+		report.Binaries = append(report.Binaries, binary)
 	}
+
 	c.Logger.Info("Compiled Go binary", "duration", time.Since(a), "buildDest", buildDest)
 	return nil
 }
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	esbuild "github.com/evanw/esbuild/pkg/api"
 	"github.com/sjc5/kit/pkg/errutil"
@@ -20,71 +21,115 @@ import (
 
 var noHashPublicDirsByVersion = map[uint8]string{0: "__nohash", 1: "prehashed"}
 
-func (c *Config) Build(recompileBinary bool, shouldBeGranular bool) error {
+func (c *Config) Build(recompileBinary bool, shouldBeGranular bool) (*BuildResult, error) {
 	enforceProperInstantiation(c)
 
+	buildStart := time.Now()
+	var timings BuildTimings
+
 	c.fileSemaphore = semaphore.NewWeighted(100)
 
 	if !shouldBeGranular {
 
 		// nuke the dist/kiruna directory
 		if err := os.RemoveAll(c.__dist.S().Kiruna.FullPath()); err != nil {
-			return fmt.Errorf("error removing dist/kiruna directory: %v", err)
+			return nil, fmt.Errorf("error removing dist/kiruna directory: %v", err)
 		}
 
 		// re-make required directories
 		if err := c.SetupDistDir(); err != nil {
-			return fmt.Errorf("error making requisite directories: %v", err)
+			return nil, fmt.Errorf("error making requisite directories: %v", err)
 		}
 	}
 
 	if !c.ServerOnly {
 		// Must be complete before BuildCSS in case the CSS references any public files
-		if err := c.handlePublicFiles(shouldBeGranular); err != nil {
-			return fmt.Errorf("error handling public files: %v", err)
+		if err := timePhase(&timings.PublicFiles, func() error {
+			return c.handlePublicFiles(shouldBeGranular)
+		}); err != nil {
+			return nil, fmt.Errorf("error handling public files: %w", err)
 		}
 
+		var scriptOutputs map[string]fileVal
+
 		var eg errgroup.Group
 		eg.Go(func() error {
-			return errutil.Maybe("error during precompile task (copyPrivateFiles)", c.copyPrivateFiles(shouldBeGranular))
+			return errutil.Maybe("error during precompile task (copyPrivateFiles)", timePhase(&timings.PrivateFiles, func() error {
+				return c.copyPrivateFiles(shouldBeGranular)
+			}))
+		})
+		eg.Go(func() error {
+			return errutil.Maybe("error during precompile task (buildCSS)", timePhase(&timings.CSS, c.buildCSS))
 		})
 		eg.Go(func() error {
-			return errutil.Maybe("error during precompile task (buildCSS)", c.buildCSS())
+			return errutil.Maybe("error during precompile task (buildScripts)", timePhase(&timings.Scripts, func() error {
+				var err error
+				scriptOutputs, err = c.buildScripts()
+				return err
+			}))
 		})
 		if err := eg.Wait(); err != nil {
-			return err
+			return nil, err
+		}
+
+		if err := c.validateTemplates(); err != nil {
+			return nil, fmt.Errorf("error validating templates: %w", err)
+		}
+
+		// Must happen after buildCSS, which reads the public file map while resolving url() references
+		if err := c.commitScriptsToPublicFileMap(scriptOutputs); err != nil {
+			return nil, fmt.Errorf("error committing scripts to public file map: %v", err)
+		}
+
+		// Must happen last, once every public file has been written
+		if err := timePhase(&timings.Precompress, c.precompressPublicFiles); err != nil {
+			return nil, fmt.Errorf("error precompressing public files: %v", err)
 		}
 	}
 
 	if recompileBinary {
-		if err := c.compileBinary(); err != nil {
-			return fmt.Errorf("error compiling binary: %v", err)
+		if err := timePhase(&timings.GoCompile, c.compileBinary); err != nil {
+			return nil, fmt.Errorf("error compiling binary: %w", err)
 		}
 	}
-	return nil
+
+	timings.Total = time.Since(buildStart)
+
+	isDev := c.getIsDev()
+
+	result, err := c.assembleBuildResult(timings, recompileBinary, !isDev || c.hasSizeBudgets())
+	if err != nil {
+		return nil, fmt.Errorf("error assembling build result: %w", err)
+	}
+	if !isDev {
+		if err := c.writeBuildReport(result); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.enforceSizeBudgets(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
 func (c *Config) buildCSS() error {
 	err := c.processCSSCritical()
 	if err != nil {
-		return fmt.Errorf("error processing critical CSS: %v", err)
+		return fmt.Errorf("error processing critical CSS: %w", err)
 	}
 
 	err = c.processCSSNormal()
 	if err != nil {
-		return fmt.Errorf("error processing normal CSS: %v", err)
+		return fmt.Errorf("error processing normal CSS: %w", err)
 	}
 
 	return nil
 }
 
 func collectEsbuildErrors(result esbuild.BuildResult) error {
-	var errors []string
-	for _, msg := range result.Errors {
-		errors = append(errors, msg.Text)
-	}
-	if len(errors) > 0 {
-		return fmt.Errorf("esbuild errors: %v", strings.Join(errors, "\n"))
+	if len(result.Errors) > 0 {
+		return &esbuildError{messages: result.Errors}
 	}
 	return nil
 }
@@ -103,14 +148,6 @@ type Metafile struct {
 	}
 }
 
-var (
-	cssImportURLsMu         *sync.RWMutex  = &sync.RWMutex{}
-	criticalReliedUponFiles                = map[string]struct{}{}
-	normalReliedUponFiles                  = map[string]struct{}{}
-	esbuildCtxCritical      esbuildCtxSafe = esbuildCtxSafe{}
-	esbuildCtxNormal        esbuildCtxSafe = esbuildCtxSafe{}
-)
-
 func (c *Config) processCSSCritical() error { return c.__processCSS("critical") }
 func (c *Config) processCSSNormal() error   { return c.__processCSS("normal") }
 
@@ -125,7 +162,7 @@ func (c *Config) __processCSS(nature string) error {
 		return nil
 	}
 
-	isDev := GetIsDev()
+	isDev := c.getIsDev()
 
 	ctx, ctxErr := esbuild.Context(esbuild.BuildOptions{
 		EntryPoints:       []string{entryPoint},
@@ -155,22 +192,22 @@ func (c *Config) __processCSS(nature string) error {
 		},
 	})
 	if ctxErr != nil {
-		return fmt.Errorf("error creating esbuild context: %v", ctxErr.Errors)
+		return fmt.Errorf("error creating esbuild context: %w", &esbuildError{messages: ctxErr.Errors})
 	}
 
 	if nature == "critical" {
-		esbuildCtxCritical.mu.Lock()
-		esbuildCtxCritical.ctx = ctx
-		esbuildCtxCritical.mu.Unlock()
+		c.esbuildCtxCritical.mu.Lock()
+		c.esbuildCtxCritical.ctx = ctx
+		c.esbuildCtxCritical.mu.Unlock()
 	} else {
-		esbuildCtxNormal.mu.Lock()
-		esbuildCtxNormal.ctx = ctx
-		esbuildCtxNormal.mu.Unlock()
+		c.esbuildCtxNormal.mu.Lock()
+		c.esbuildCtxNormal.ctx = ctx
+		c.esbuildCtxNormal.mu.Unlock()
 	}
 
 	result := ctx.Rebuild()
 	if err := collectEsbuildErrors(result); err != nil {
-		return fmt.Errorf("error building CSS: %v", err)
+		return fmt.Errorf("error building CSS: %w", err)
 	}
 
 	var metafile Metafile
@@ -185,12 +222,12 @@ func (c *Config) __processCSS(nature string) error {
 
 	imports := metafile.Inputs[srcURL].Imports
 
-	cssImportURLsMu.Lock()
+	c.cssImportURLsMu.Lock()
 
 	if nature == "critical" {
-		criticalReliedUponFiles = map[string]struct{}{}
+		c.criticalReliedUponFiles = map[string]struct{}{}
 	} else {
-		normalReliedUponFiles = map[string]struct{}{}
+		c.normalReliedUponFiles = map[string]struct{}{}
 	}
 
 	for _, imp := range imports {
@@ -199,13 +236,19 @@ func (c *Config) __processCSS(nature string) error {
 		}
 
 		if nature == "critical" {
-			criticalReliedUponFiles[imp.Path] = struct{}{}
+			c.criticalReliedUponFiles[imp.Path] = struct{}{}
 		} else {
-			normalReliedUponFiles[imp.Path] = struct{}{}
+			c.normalReliedUponFiles[imp.Path] = struct{}{}
 		}
 	}
 
-	cssImportURLsMu.Unlock()
+	err := c.saveCSSPreloadURLsLocked(nature, getCSSPreloadURLs(&metafile))
+
+	c.cssImportURLsMu.Unlock()
+
+	if err != nil {
+		return err
+	}
 
 	// Determine output path and filename
 	var outputPath string
@@ -244,12 +287,25 @@ func (c *Config) __processCSS(nature string) error {
 	// Write css to file
 	outputFile := filepath.Join(outputPath, outputFileName)
 
+	hashedPath, err := filepath.Rel(c.__dist.S().Kiruna.FullPath(), outputFile)
+	if err != nil {
+		return fmt.Errorf("error getting CSS output path: %v", err)
+	}
+	c.recordCSSBundle(nature, &cssBundleRecord{
+		entry:       filepath.ToSlash(entryPoint),
+		hashedPath:  filepath.ToSlash(hashedPath),
+		importGraph: getCSSImportGraph(&metafile),
+	}, result.Warnings)
+
 	// If normal, also write to a file called normal_css_ref.txt with the hash
 	if nature == "normal" {
 		hashFile := c.__dist.S().Kiruna.S().Internal.S().NormalCSSFileRefDotTXT.FullPath()
 		if err := os.WriteFile(hashFile, []byte(outputFileName), 0644); err != nil {
 			return fmt.Errorf("error writing to file: %v", err)
 		}
+		if err := c.recordGeneratedPublicFile(generatedPublicNormalCSS, outputFileName, result.OutputFiles[0].Contents); err != nil {
+			return err
+		}
 	}
 
 	return os.WriteFile(outputFile, result.OutputFiles[0].Contents, 0644)
@@ -305,11 +361,6 @@ type fileInfo struct {
 	isNoHashDir  bool
 }
 
-// __TODO this should probably be a config option and use glob patterns
-var STATIC_FILES_IGNORE_LIST = map[string]struct{}{
-	".DS_Store": {},
-}
-
 func (c *Config) processStaticFiles(opts *staticFileProcessorOpts) error {
 	if _, err := os.Stat(opts.srcDir); os.IsNotExist(err) {
 		return nil
@@ -351,7 +402,7 @@ func (c *Config) processStaticFiles(opts *staticFileProcessorOpts) error {
 				if isNoHashDir {
 					relativePath = strings.TrimPrefix(relativePath, noHashPublicDirsByVersion[version]+"/")
 				}
-				if _, isIgnore := STATIC_FILES_IGNORE_LIST[relativePath]; isIgnore {
+				if _, isIgnore := c.staticFilesIgnoreList[relativePath]; isIgnore {
 					return nil
 				}
 				fileChan <- fileInfo{path: path, relativePath: relativePath, isNoHashDir: isNoHashDir}
@@ -387,6 +438,22 @@ func (c *Config) processStaticFiles(opts *staticFileProcessorOpts) error {
 		return err
 	}
 
+	// Carry forward the outputs of the most recent script build, as those
+	// live in the public file map too but don't come from the public dir
+	if opts.basename == PUBLIC {
+		scriptOutputs, err := c.loadScriptsFileRef()
+		if err != nil {
+			return err
+		}
+		for name, output := range scriptOutputs {
+			key := scriptFileMapKey(name)
+			if _, exists := newFileMap.Load(key); exists {
+				return newScriptCollisionError(name)
+			}
+			newFileMap.Store(key, output)
+		}
+	}
+
 	// Cleanup old moot files if granular updates are enabled
 	if opts.shouldBeGranular {
 		var oldMapErr error
@@ -409,16 +476,12 @@ func (c *Config) processStaticFiles(opts *staticFileProcessorOpts) error {
 	}
 
 	// Save the updated file map
-	err := c.saveMapToGob(toStdMap(&newFileMap), opts.mapName)
-	if err != nil {
-		return fmt.Errorf("error saving file map: %v", err)
+	if opts.basename == PUBLIC {
+		return c.savePublicFileMap(toStdMap(&newFileMap))
 	}
 
-	if opts.basename == PUBLIC {
-		err = c.savePublicFileMapJSToInternalPublicDir(toStdMap(&newFileMap))
-		if err != nil {
-			return fmt.Errorf("error saving public file map JSON: %v", err)
-		}
+	if err := c.saveMapToGob(toStdMap(&newFileMap), opts.mapName); err != nil {
+		return fmt.Errorf("error saving file map: %v", err)
 	}
 
 	return nil
@@ -442,13 +505,22 @@ func (c *Config) processFile(
 	if fi.isNoHashDir {
 		fileIdentifier.Val = fi.relativePath
 		fileIdentifier.IsPrehashed = true
+		if opts.basename == PUBLIC {
+			integrity, err := getSRIDigestFromPath(fi.path)
+			if err != nil {
+				return fmt.Errorf("error getting SRI digest: %v", err)
+			}
+			fileIdentifier.Integrity = integrity
+		}
 	} else {
-		var err error
-		name, err := getHashedFilenameFromPath(fi.path, relativePathUnderscores)
+		name, integrity, err := getHashedFilenameFromPath(fi.path, relativePathUnderscores)
 		if err != nil {
 			return fmt.Errorf("error getting hashed filename: %v", err)
 		}
 		fileIdentifier.Val = name
+		if opts.basename == PUBLIC {
+			fileIdentifier.Integrity = integrity
+		}
 	}
 
 	newFileMap.Store(fi.relativePath, fileIdentifier)
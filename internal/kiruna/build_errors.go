@@ -0,0 +1,128 @@
+package ik
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	goruntime "runtime"
+	"strings"
+
+	esbuild "github.com/evanw/esbuild/pkg/api"
+)
+
+type buildErrorSource string
+
+const (
+	buildErrorSourceGo       buildErrorSource = "go"
+	buildErrorSourceEsbuild  buildErrorSource = "esbuild"
+	buildErrorSourceCallback buildErrorSource = "callback"
+	buildErrorSourceOther    buildErrorSource = "other"
+)
+
+// buildErrorPayload is the structured form of a failed dev rebuild,
+// sent to the browser so that it can render an error overlay.
+type buildErrorPayload struct {
+	Source          buildErrorSource `json:"source"`
+	Message         string           `json:"message"`
+	CompilerOutput  string           `json:"compilerOutput,omitempty"`
+	EsbuildMessages []esbuildMessage `json:"esbuildMessages,omitempty"`
+	Callback        string           `json:"callback,omitempty"`
+}
+
+type esbuildMessage struct {
+	Text     string `json:"text"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	LineText string `json:"lineText,omitempty"`
+}
+
+func toEsbuildMessage(msg esbuild.Message) esbuildMessage {
+	m := esbuildMessage{Text: msg.Text}
+	if msg.Location != nil {
+		m.File = msg.Location.File
+		m.Line = msg.Location.Line
+		m.Column = msg.Location.Column
+		m.LineText = msg.Location.LineText
+	}
+	return m
+}
+
+type goCompileError struct {
+	output string
+	err    error
+}
+
+func (e *goCompileError) Error() string { return fmt.Sprintf("error compiling binary: %v", e.err) }
+func (e *goCompileError) Unwrap() error { return e.err }
+
+type esbuildError struct {
+	messages []esbuild.Message
+}
+
+func (e *esbuildError) Error() string {
+	texts := make([]string, 0, len(e.messages))
+	for _, msg := range e.messages {
+		texts = append(texts, msg.Text)
+	}
+	return fmt.Sprintf("esbuild errors: %v", strings.Join(texts, "\n"))
+}
+
+type onChangeCallbackError struct {
+	name string
+	err  error
+}
+
+func (e *onChangeCallbackError) Error() string {
+	return fmt.Sprintf("error running extension callback (%s): %v", e.name, e.err)
+}
+func (e *onChangeCallbackError) Unwrap() error { return e.err }
+
+func (o *OnChange) getName() string {
+	if o.Name != "" {
+		return o.Name
+	}
+	if o.Func == nil {
+		return "<nil>"
+	}
+	if fn := goruntime.FuncForPC(reflect.ValueOf(o.Func).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return "<unknown>"
+}
+
+func toBuildErrorPayload(err error) *buildErrorPayload {
+	payload := &buildErrorPayload{Source: buildErrorSourceOther, Message: err.Error()}
+
+	var callbackErr *onChangeCallbackError
+	if errors.As(err, &callbackErr) {
+		payload.Source = buildErrorSourceCallback
+		payload.Callback = callbackErr.name
+	}
+
+	var compileErr *goCompileError
+	if errors.As(err, &compileErr) {
+		payload.Source = buildErrorSourceGo
+		payload.CompilerOutput = compileErr.output
+	}
+
+	var ebErr *esbuildError
+	if errors.As(err, &ebErr) {
+		payload.Source = buildErrorSourceEsbuild
+		for _, msg := range ebErr.messages {
+			payload.EsbuildMessages = append(payload.EsbuildMessages, toEsbuildMessage(msg))
+		}
+	}
+
+	return payload
+}
+
+func (c *Config) broadcastBuildError(err error) {
+	if c.ServerOnly {
+		return
+	}
+	c.manager.broadcastPayload(refreshFilePayload{
+		ChangeType: changeTypeBuildError,
+		BuildError: toBuildErrorPayload(err),
+	})
+}
@@ -0,0 +1,63 @@
+package ik
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestToBuildErrorPayloadEsbuild(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "critical.css", "body { color: red; }")
+	env.createTestFile(t, "main.css", "@import \"./missing.css\";")
+
+	err := env.config.buildCSS()
+	if err == nil {
+		t.Fatalf("buildCSS() error = nil, want error")
+	}
+
+	payload := toBuildErrorPayload(fmt.Errorf("wrapped: %w", err))
+	if payload.Source != buildErrorSourceEsbuild {
+		t.Fatalf("Source = %v, want %v", payload.Source, buildErrorSourceEsbuild)
+	}
+	if len(payload.EsbuildMessages) == 0 {
+		t.Fatalf("EsbuildMessages is empty")
+	}
+	if msg := payload.EsbuildMessages[0]; msg.File == "" || msg.Line != 1 {
+		t.Errorf("EsbuildMessages[0] = %+v, want file and line 1", msg)
+	}
+}
+
+func TestToBuildErrorPayloadCallback(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	onChanges := []OnChange{{
+		Name: "gen-types",
+		Func: func() error { return errors.New("boom") },
+	}}
+
+	err := env.config.simpleRunOnChangeCallbacks(&onChanges, "some/file.go")
+	if err == nil {
+		t.Fatalf("simpleRunOnChangeCallbacks() error = nil, want error")
+	}
+
+	payload := toBuildErrorPayload(err)
+	if payload.Source != buildErrorSourceCallback || payload.Callback != "gen-types" {
+		t.Errorf("payload = %+v, want callback source named gen-types", payload)
+	}
+}
+
+func TestToBuildErrorPayloadGo(t *testing.T) {
+	err := fmt.Errorf("error: failed to build app: %w", &goCompileError{
+		output: "./main.go:3:1: syntax error",
+		err:    errors.New("exit status 1"),
+	})
+
+	payload := toBuildErrorPayload(err)
+	if payload.Source != buildErrorSourceGo || payload.CompilerOutput != "./main.go:3:1: syntax error" {
+		t.Errorf("payload = %+v, want go source with compiler output", payload)
+	}
+}
@@ -0,0 +1,353 @@
+package ik
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"sort"
+	"time"
+
+	esbuild "github.com/evanw/esbuild/pkg/api"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	BuildAssetKindPublic  = "public"
+	BuildAssetKindPrivate = "private"
+	BuildAssetKindScript  = "script"
+	BuildAssetKindCSS     = "css"
+)
+
+const (
+	buildWarningSourceCriticalCSS = "critical-css"
+	buildWarningSourceNormalCSS   = "normal-css"
+	buildWarningSourceScripts     = "scripts"
+)
+
+// BuildResult describes everything a call to Build produced. In prod, it is
+// also written to dist/kiruna/build_report.json, so that builds can be diffed.
+// In dev, Assets and CSSBundles are left empty (unless size budgets need them),
+// as listing them on every granular rebuild is wasted work.
+type BuildResult struct {
+	Assets     []BuildAsset     `json:"assets"`
+	CSSBundles []BuildCSSBundle `json:"cssBundles"`
+	Timings    BuildTimings     `json:"timings"`
+	Warnings   []BuildWarning   `json:"warnings"`
+
+	// Only set if the build compiled the Go binary
+	GoBuild *BuildGoReport `json:"goBuild,omitempty"`
+}
+
+type BuildAsset struct {
+	Kind string `json:"kind"` // "public", "private", "script", or "css"
+
+	// For public and private files, the path relative to the source static dir.
+	// For scripts and CSS, the entry file.
+	OriginalPath string `json:"originalPath"`
+
+	// The path the asset was written to, relative to dist/kiruna
+	// (e.g., "static/public/normal_xxx.css"). Private files are not hashed.
+	HashedPath string `json:"hashedPath"`
+
+	Size int64 `json:"size"`
+
+	// Keyed by Content-Encoding token ("br", "gzip"). Only populated in prod
+	// builds, and only for compressible content types. Taken from precompressed
+	// siblings when they exist, and computed in memory otherwise (only if
+	// Config.ReportCompressedSizes is true).
+	CompressedSizes map[string]int64 `json:"compressedSizes,omitempty"`
+
+	ContentType string `json:"contentType"`
+}
+
+type BuildCSSBundle struct {
+	BuildAsset
+	Nature string `json:"nature"` // "critical" or "normal"
+
+	// Every file in the bundle, mapped to the files it pulls in via @import
+	ImportGraph map[string][]string `json:"importGraph"`
+}
+
+// BuildTimings are serialized to JSON as milliseconds.
+type BuildTimings struct {
+	PublicFiles  time.Duration
+	PrivateFiles time.Duration
+	CSS          time.Duration
+	Scripts      time.Duration
+	Precompress  time.Duration
+	GoCompile    time.Duration
+	Total        time.Duration
+}
+
+func (t BuildTimings) MarshalJSON() ([]byte, error) {
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	return json.Marshal(struct {
+		PublicFiles  float64 `json:"publicFilesMs"`
+		PrivateFiles float64 `json:"privateFilesMs"`
+		CSS          float64 `json:"cssMs"`
+		Scripts      float64 `json:"scriptsMs"`
+		Precompress  float64 `json:"precompressMs"`
+		GoCompile    float64 `json:"goCompileMs"`
+		Total        float64 `json:"totalMs"`
+	}{
+		ms(t.PublicFiles), ms(t.PrivateFiles), ms(t.CSS), ms(t.Scripts),
+		ms(t.Precompress), ms(t.GoCompile), ms(t.Total),
+	})
+}
+
+type BuildWarning struct {
+	Source   string `json:"source"` // "critical-css", "normal-css", or "scripts"
+	Text     string `json:"text"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	LineText string `json:"lineText,omitempty"`
+}
+
+type cssBundleRecord struct {
+	entry       string
+	hashedPath  string // relative to dist/kiruna
+	importGraph map[string][]string
+}
+
+// timePhase runs fn and records its duration into dest.
+func timePhase(dest *time.Duration, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	*dest = time.Since(start)
+	return err
+}
+
+func (c *Config) recordCSSBundle(nature string, record *cssBundleRecord, warnings []esbuild.Message) {
+	source := buildWarningSourceNormalCSS
+	if nature == "critical" {
+		source = buildWarningSourceCriticalCSS
+	}
+
+	c.buildReportMu.Lock()
+	defer c.buildReportMu.Unlock()
+
+	if c.cssBundleRecords == nil {
+		c.cssBundleRecords = make(map[string]*cssBundleRecord)
+	}
+	c.cssBundleRecords[nature] = record
+	c.setEsbuildWarningsLocked(source, warnings)
+}
+
+func (c *Config) recordScriptWarnings(warnings []esbuild.Message) {
+	c.buildReportMu.Lock()
+	defer c.buildReportMu.Unlock()
+	c.setEsbuildWarningsLocked(buildWarningSourceScripts, warnings)
+}
+
+func (c *Config) setEsbuildWarningsLocked(source string, warnings []esbuild.Message) {
+	if c.esbuildWarnings == nil {
+		c.esbuildWarnings = make(map[string][]esbuild.Message)
+	}
+	c.esbuildWarnings[source] = warnings
+}
+
+// assembleBuildResult only lists assets and CSS bundles if withAssets is true.
+func (c *Config) assembleBuildResult(timings BuildTimings, recompiledBinary, withAssets bool) (*BuildResult, error) {
+	result := &BuildResult{
+		Assets:     []BuildAsset{},
+		CSSBundles: []BuildCSSBundle{},
+		Timings:    timings,
+		Warnings:   []BuildWarning{},
+	}
+
+	if recompiledBinary {
+		c.buildReportMu.Lock()
+		result.GoBuild = c.goBuildReport
+		c.buildReportMu.Unlock()
+	}
+
+	if c.ServerOnly {
+		return result, nil
+	}
+
+	c.buildReportMu.Lock()
+	records := make(map[string]*cssBundleRecord, len(c.cssBundleRecords))
+	for nature, record := range c.cssBundleRecords {
+		records[nature] = record
+	}
+	for _, source := range []string{buildWarningSourceCriticalCSS, buildWarningSourceNormalCSS, buildWarningSourceScripts} {
+		for _, msg := range c.esbuildWarnings[source] {
+			m := toEsbuildMessage(msg)
+			result.Warnings = append(result.Warnings, BuildWarning{
+				Source: source, Text: m.Text, File: m.File, Line: m.Line, Column: m.Column, LineText: m.LineText,
+			})
+		}
+	}
+	c.buildReportMu.Unlock()
+
+	if !withAssets {
+		return result, nil
+	}
+
+	assets, err := c.collectStaticAssets()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, nature := range []string{"critical", "normal"} {
+		record, ok := records[nature]
+		if !ok {
+			continue
+		}
+		result.CSSBundles = append(result.CSSBundles, BuildCSSBundle{
+			BuildAsset:  BuildAsset{Kind: BuildAssetKindCSS, OriginalPath: record.entry, HashedPath: record.hashedPath},
+			Nature:      nature,
+			ImportGraph: record.importGraph,
+		})
+	}
+
+	// Stat (and maybe compress) everything concurrently
+	var eg errgroup.Group
+	eg.SetLimit(goruntime.NumCPU())
+	for i := range assets {
+		eg.Go(func() error { return c.fillBuildAssetDetails(&assets[i]) })
+	}
+	for i := range result.CSSBundles {
+		eg.Go(func() error { return c.fillBuildAssetDetails(&result.CSSBundles[i].BuildAsset) })
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		if assets[i].Kind != assets[j].Kind {
+			return assets[i].Kind < assets[j].Kind
+		}
+		return assets[i].OriginalPath < assets[j].OriginalPath
+	})
+	result.Assets = assets
+
+	return result, nil
+}
+
+func (c *Config) collectStaticAssets() ([]BuildAsset, error) {
+	publicFileMap, err := c.loadMapFromGobIfExists(PublicFileMapGobName)
+	if err != nil {
+		return nil, fmt.Errorf("error reading public file map: %v", err)
+	}
+
+	privateFileMap, err := c.loadMapFromGobIfExists(PrivateFileMapGobName)
+	if err != nil {
+		return nil, fmt.Errorf("error reading private file map: %v", err)
+	}
+
+	scriptKeys := make(map[string]string, len(c.cleanSources.ScriptEntries))
+	for name, entry := range c.cleanSources.ScriptEntries {
+		scriptKeys[scriptFileMapKey(name)] = entry
+	}
+
+	assets := make([]BuildAsset, 0, len(publicFileMap)+len(privateFileMap))
+
+	for key, val := range publicFileMap {
+		asset := BuildAsset{Kind: BuildAssetKindPublic, OriginalPath: key, HashedPath: "static/" + PUBLIC + "/" + val.Val}
+		if entry, isScript := scriptKeys[key]; isScript {
+			asset.Kind = BuildAssetKindScript
+			asset.OriginalPath = filepath.ToSlash(entry)
+		}
+		assets = append(assets, asset)
+	}
+
+	for key := range privateFileMap {
+		assets = append(assets, BuildAsset{Kind: BuildAssetKindPrivate, OriginalPath: key, HashedPath: "static/" + PRIVATE + "/" + key})
+	}
+
+	return assets, nil
+}
+
+// loadMapFromGobIfExists returns an empty map if the gob was never written
+// (which is the case when the corresponding static source dir doesn't exist).
+func (c *Config) loadMapFromGobIfExists(gobFileName string) (FileMap, error) {
+	gobPath := filepath.Join(c.__dist.S().Kiruna.S().Internal.FullPath(), gobFileName)
+	if _, err := os.Stat(gobPath); os.IsNotExist(err) {
+		return FileMap{}, nil
+	}
+	return c.loadMapFromGob(gobFileName, true)
+}
+
+// fillBuildAssetDetails sets the size, compressed sizes and content type of
+// an asset whose HashedPath is set.
+func (c *Config) fillBuildAssetDetails(asset *BuildAsset) error {
+	distPath := filepath.Join(c.__dist.S().Kiruna.FullPath(), filepath.FromSlash(asset.HashedPath))
+
+	asset.ContentType = mime.TypeByExtension(filepath.Ext(asset.HashedPath))
+	if asset.ContentType == "" {
+		asset.ContentType = "application/octet-stream"
+	}
+
+	info, err := os.Stat(distPath)
+	if err != nil {
+		return fmt.Errorf("error reading emitted asset %s: %v", asset.HashedPath, err)
+	}
+	asset.Size = info.Size()
+
+	if c.getIsDev() || !getIsCompressible(distPath) {
+		return nil
+	}
+
+	var content []byte
+	asset.CompressedSizes = make(map[string]int64, len(precompressedEncodings))
+	defer func() {
+		if len(asset.CompressedSizes) == 0 {
+			asset.CompressedSizes = nil
+		}
+	}()
+
+	for _, enc := range precompressedEncodings {
+		if siblingInfo, err := os.Stat(distPath + enc.ext); err == nil {
+			asset.CompressedSizes[enc.name] = siblingInfo.Size()
+			continue
+		}
+		if !c.ReportCompressedSizes {
+			continue
+		}
+		if content == nil {
+			if content, err = os.ReadFile(distPath); err != nil {
+				return fmt.Errorf("error reading emitted asset %s: %v", asset.HashedPath, err)
+			}
+		}
+		var buf bytes.Buffer
+		if err := enc.compress(&buf, content); err != nil {
+			return fmt.Errorf("error compressing %s (%s): %v", asset.HashedPath, enc.name, err)
+		}
+		asset.CompressedSizes[enc.name] = int64(buf.Len())
+	}
+
+	return nil
+}
+
+func (c *Config) writeBuildReport(result *BuildResult) error {
+	reportBytes, err := json.MarshalIndent(result, "", "\t")
+	if err != nil {
+		return fmt.Errorf("error marshalling build report: %v", err)
+	}
+	reportPath := c.__dist.S().Kiruna.S().BuildReportDotJSON.FullPath()
+	if err := os.WriteFile(reportPath, reportBytes, 0644); err != nil {
+		return fmt.Errorf("error writing build report: %v", err)
+	}
+	return nil
+}
+
+func getCSSImportGraph(metafile *Metafile) map[string][]string {
+	graph := make(map[string][]string, len(metafile.Inputs))
+	for path, input := range metafile.Inputs {
+		imports := []string{}
+		for _, imp := range input.Imports {
+			if imp.Kind == "import-rule" {
+				imports = append(imports, imp.Path)
+			}
+		}
+		sort.Strings(imports)
+		graph[path] = imports
+	}
+	return graph
+}
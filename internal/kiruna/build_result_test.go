@@ -0,0 +1,115 @@
+package ik
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildResult(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "critical.css", "body { color: red; }")
+	env.createTestFile(t, "styles/base.css", "p { margin: 0; }")
+	env.createTestFile(t, "main.css", "@import './styles/base.css';\np { font-size: 16px; }")
+	env.createTestFile(t, "client/main.ts", "console.log('hello');")
+	env.createTestFile(t, "public-static/favicon.ico", "icon")
+	env.createTestFile(t, "private-static/template.html", "<html></html>")
+
+	env.config.cleanSources.ScriptEntries = map[string]string{
+		"main": filepath.Join(testRootDir, "client/main.ts"),
+	}
+	env.config.ReportCompressedSizes = true
+
+	result, err := env.config.Build(false, false)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	byKind := map[string]BuildAsset{}
+	for _, asset := range result.Assets {
+		byKind[asset.Kind] = asset
+		if _, err := os.Stat(filepath.Join(testRootDir, "dist/kiruna", asset.HashedPath)); err != nil {
+			t.Errorf("asset %s not found at its HashedPath: %v", asset.OriginalPath, err)
+		}
+		if asset.Size == 0 {
+			t.Errorf("asset %s has zero size", asset.OriginalPath)
+		}
+	}
+
+	if got := byKind[BuildAssetKindPublic]; got.OriginalPath != "favicon.ico" {
+		t.Errorf("public asset = %+v, want favicon.ico", got)
+	}
+	if got := byKind[BuildAssetKindPrivate]; got.HashedPath != "static/private/template.html" {
+		t.Errorf("private asset = %+v, want static/private/template.html", got)
+	}
+	if got := byKind[BuildAssetKindScript]; got.OriginalPath != "testdata/client/main.ts" || got.ContentType == "" {
+		t.Errorf("script asset = %+v", got)
+	}
+
+	if len(result.CSSBundles) != 2 {
+		t.Fatalf("len(CSSBundles) = %d, want 2", len(result.CSSBundles))
+	}
+	normal := result.CSSBundles[1]
+	if normal.Nature != "normal" || normal.CompressedSizes["gzip"] == 0 || normal.CompressedSizes["br"] == 0 {
+		t.Errorf("normal CSS bundle = %+v", normal)
+	}
+	if imports := normal.ImportGraph["testdata/main.css"]; len(imports) != 1 || imports[0] != "testdata/styles/base.css" {
+		t.Errorf("normal CSS import graph = %v", normal.ImportGraph)
+	}
+
+	reportBytes, err := os.ReadFile(filepath.Join(testRootDir, "dist/kiruna/build_report.json"))
+	if err != nil {
+		t.Fatalf("failed to read build report: %v", err)
+	}
+	var report map[string]any
+	if err := json.Unmarshal(reportBytes, &report); err != nil {
+		t.Fatalf("build report is not valid JSON: %v", err)
+	}
+	if _, ok := report["timings"].(map[string]any)["cssMs"]; !ok {
+		t.Errorf("build report timings = %v, want cssMs", report["timings"])
+	}
+}
+
+func TestBuildResultWithoutCompressedSizes(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "critical.css", "body { color: red; }")
+	env.createTestFile(t, "main.css", "p { font-size: 16px; }")
+
+	result, err := env.config.Build(false, false)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	for _, bundle := range result.CSSBundles {
+		if bundle.CompressedSizes != nil {
+			t.Errorf("%s CSS bundle CompressedSizes = %v, want none without precompressed siblings", bundle.Nature, bundle.CompressedSizes)
+		}
+	}
+}
+
+func TestBuildResultDev(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+	defer resetEnv()
+
+	env.createTestFile(t, "critical.css", "body { color: red; }")
+	env.createTestFile(t, "main.css", "p { font-size: 16px; }")
+	env.createTestFile(t, "public-static/favicon.ico", "icon")
+
+	env.config.setModeToDev()
+
+	result, err := env.config.Build(false, false)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if len(result.Assets) != 0 || len(result.CSSBundles) != 0 {
+		t.Errorf("dev build result lists %d assets and %d CSS bundles, want none", len(result.Assets), len(result.CSSBundles))
+	}
+	if _, err := os.Stat(filepath.Join(testRootDir, "dist/kiruna/build_report.json")); !os.IsNotExist(err) {
+		t.Errorf("dev build wrote a build report")
+	}
+}
@@ -0,0 +1,88 @@
+package ik
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// The refresh script reports errors from the page back over the refresh
+// WebSocket, so that they show up in the dev terminal.
+
+const (
+	clientMessageTypeError = "client-error"
+	clientMessageReadLimit = 64 * 1024
+	clientErrorMaxLen      = 4 * 1024
+)
+
+type clientErrorReport struct {
+	Type    string `json:"type"`
+	Kind    string `json:"kind"` // "error", "unhandledrejection", or "console.error"
+	Message string `json:"message"`
+	Stack   string `json:"stack"`
+	Source  string `json:"source"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	PageURL string `json:"pageURL"`
+}
+
+// handleClientMessage ignores anything it doesn't recognize, as it comes
+// straight from the browser.
+func (manager *clientManager) handleClientMessage(data []byte) {
+	if manager.onClientError == nil {
+		return
+	}
+	var report clientErrorReport
+	if err := json.Unmarshal(data, &report); err != nil || report.Type != clientMessageTypeError {
+		return
+	}
+	manager.onClientError(report)
+}
+
+func (c *Config) logClientError(report clientErrorReport) {
+	msg := fmt.Sprintf("browser %s: %s", sanitizeClientErrorField(report.Kind), sanitizeClientErrorField(report.Message))
+	if stack := sanitizeClientErrorStack(report.Stack); stack != "" {
+		msg += "\n" + stack
+	}
+	args := []any{"page", sanitizeClientErrorField(report.PageURL)}
+	if source := sanitizeClientErrorField(report.Source); source != "" {
+		args = append(args, "source", fmt.Sprintf("%s:%d:%d", source, report.Line, report.Column))
+	}
+	c.Logger.Error(msg, args...)
+}
+
+// sanitizeClientErrorField truncates s and strips control characters
+// (including newlines and terminal escapes), so that a page can't forge
+// log lines or mess with the terminal.
+func sanitizeClientErrorField(s string) string {
+	return truncateClientErrorField(stripControlChars(s))
+}
+
+// sanitizeClientErrorStack keeps a stack's lines, but indents each of them
+// so that none can pass for a log line of its own.
+func sanitizeClientErrorStack(stack string) string {
+	var lines []string
+	for _, line := range strings.Split(truncateClientErrorField(stack), "\n") {
+		if line = strings.TrimSpace(stripControlChars(line)); line != "" {
+			lines = append(lines, "    "+line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func stripControlChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func truncateClientErrorField(s string) string {
+	if len(s) <= clientErrorMaxLen {
+		return s
+	}
+	return s[:clientErrorMaxLen] + "... (truncated)"
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io/fs"
 	"log/slog"
+	"net/url"
+	"strings"
 	"sync"
 
 	"github.com/sjc5/kit/pkg/dirs"
@@ -12,11 +14,14 @@ import (
 type Config struct {
 	dev
 	runtime
+	buildtime
 	initializedWithNew bool
 	commonInitOnce     sync.Once
 	devConfig          *DevConfig
 	cleanSources       CleanSources
 	cleanWatchRoot     string
+	publicPathPrefix   string // always has leading and trailing slashes
+	publicAssetBaseURL string // always has a trailing slash, or is empty
 	__dist             *dirs.Dir[Dist]
 
 	// If not nil, the embedded file system will be used in production builds.
@@ -50,6 +55,68 @@ type Config struct {
 	// Set this relative to the directory you're running commands from (e.g., "./main.css").
 	NormalCSSEntry string
 
+	// Map of script names to JS/TS entry files, with the entry files set relative to the
+	// directory you're running commands from (e.g., {"main": "./client/main.ts"}). Each
+	// entry is bundled by esbuild, minified in prod, and content-hashed into the public
+	// dist dir, where it is recorded in the public file map as "<name>.js".
+	// Use GetScriptURL("main") or GetScriptElements() to reference the output.
+	// In dev, entries can opt into hot module replacement via import.meta.hot (accept,
+	// dispose, data, and invalidate). Updates nothing accepts fall back to a page reload.
+	ScriptEntries map[string]string
+
+	// If true, gzip (".gz") and brotli (".br") siblings are written next to compressible
+	// public files (CSS, JS, SVG, JSON, fonts, etc.) during prod builds, and the handler
+	// returned by GetServeStaticHandler picks the best one based on the request's
+	// Accept-Encoding header. Works with both the embedded DistFS and the disk FS.
+	PrecompressPublicFiles bool
+
+	// If true, prod build reports include gzip and brotli sizes for every compressible
+	// asset, compressing in memory where no precompressed sibling exists. Otherwise,
+	// compressed sizes are only reported for assets with precompressed siblings.
+	ReportCompressedSizes bool
+
+	// URL path prefix under which public files are served (e.g., "/assets/"). Defaults
+	// to "/public/". Used by every public URL generator, and stripped by the handler
+	// returned by GetServeStaticHandler.
+	PublicPathPrefix string
+
+	// Optional absolute base URL that public URLs are generated against in prod, for
+	// serving assets from a CDN (e.g., "https://cdn.example.com/assets"). Requests for
+	// "<PublicAssetBaseURL>/<hashed file>" are expected to end up at your static handler
+	// (or wherever your CDN pulls from). Ignored in dev, where assets are always served
+	// locally under PublicPathPrefix.
+	PublicAssetBaseURL string
+
+	// Public files smaller than this many bytes are not precompressed. Defaults to 1024.
+	// Only relevant if PrecompressPublicFiles is true.
+	PrecompressMinBytes int
+
+	// Cache-Control overrides for public files served by GetServeStaticHandler, keyed by
+	// glob pattern (doublestar syntax) relative to PublicStaticDir (e.g.,
+	// {"images/**": "public, max-age=86400"}). If several patterns match, the longest
+	// one wins. Files Kiruna generates (the normal CSS bundle and the public file map
+	// module) are matched by their served names instead.
+	PublicCacheControl map[string]string
+
+	// Optional html/template support on top of the private static dir (see GetTemplates).
+	// Templates are parsed during every build, so that syntax errors fail the build.
+	Templates TemplatesConfig
+
+	// Size budgets checked at the end of every build. In prod, a violation fails the
+	// build with a table of the offenders. In dev, violations are only logged, and
+	// shown in the browser console.
+	SizeBudgets SizeBudgets
+
+	// Flags, build tags, ldflags, and env for compiling your app binary, with separate
+	// dev and prod values, plus optional cross-compilation targets for prod builds.
+	// Recorded in the build report.
+	GoBuild GoBuildOptions
+
+	// Additional Go programs (e.g., workers or sidecars) built alongside MainAppEntry,
+	// with the same GoBuild settings. In dev, each runs as its own process, and is only
+	// rebuilt and restarted when a Go file in its import graph changes.
+	GoBinaries []GoBinary
+
 	Logger     *slog.Logger
 	ServerOnly bool // If true, skips static asset processing/serving and browser reloading.
 }
@@ -60,8 +127,14 @@ type CleanSources struct {
 	PublicStatic     string
 	CriticalCSSEntry string
 	NormalCSSEntry   string
+	ScriptEntries    map[string]string
 }
 
+// DevConfig configures the dev server. The output of your app, Go binaries, and
+// sidecars is prefixed with their name, so it reaches the terminal through a pipe.
+// When the dev server runs in a terminal, they get FORCE_COLOR=1 and CLICOLOR_FORCE=1
+// (unless already set, or NO_COLOR is), but loggers that only check whether their
+// output is a TTY will print without color.
 type DevConfig struct {
 	// WatchRoot is the outermost directory to watch for changes in, and your
 	// dev config watched files will be set relative to this directory. If you
@@ -71,6 +144,49 @@ type DevConfig struct {
 	HealthcheckEndpoint string // e.g., "/healthz" -- should return 200 OK if healthy -- defaults to "/"
 	WatchedFiles        WatchedFiles
 	IgnorePatterns      IgnorePatterns
+
+	// If true, Kiruna listens on your app's dev port (PORT) with a reverse proxy, and
+	// runs your app on a separate internal port behind it. The proxy also serves the
+	// refresh WebSocket and refresh script on the same origin, so the browser only ever
+	// talks to one origin. Useful behind Docker port mapping, on remote dev boxes, or
+	// with a strict CSP connect-src. Ignored if Config.ServerOnly is true.
+	UseProxy bool
+
+	// If true (and UseProxy is true), the proxy injects the refresh script into HTML
+	// responses from your app, so your templates don't need to include GetRefreshScript
+	// (which renders nothing in this mode, to avoid loading the script twice).
+	InjectRefreshScript bool
+
+	// If true, changes under PrivateStaticDir no longer restart your app. Instead, the
+	// changed files are copied over, templates are re-validated, callbacks registered
+	// in your app with OnPrivateFilesChangeDev are run (so you can drop any caches of
+	// your own), and only then is the browser reloaded. Ignored if Config.ServerOnly is
+	// true, as there is then no dev server for your app to receive these callbacks from.
+	HotReloadPrivateFiles bool
+
+	// If true, changes to images, fonts, and other media under PublicStaticDir no longer
+	// restart your app or reload the browser. Instead, the refresh script patches every
+	// reference to the old hashed URL in the page (src, srcset, href, and url() in inline
+	// styles), and hot reloads your CSS. Other public files trigger a plain browser reload.
+	HotSwapPublicFiles bool
+
+	// Uncaught errors and unhandled promise rejections in pages running the refresh
+	// script are always printed in the dev terminal, with the page URL and source
+	// location. If true, console.error calls are forwarded too.
+	ForwardConsoleErrors bool
+
+	// Go file changes only recompile and restart your app if the file is part of the
+	// app binary, per "go list -deps" on MainAppEntry with your current build tags
+	// (the graph is recomputed after every recompile, including after go.mod or go.work
+	// changes). Test files, files excluded by build constraints, and packages only other
+	// binaries use are handled like non-Go files instead: processed if a WatchedFile
+	// matches them, and otherwise passed to this func (if set) and skipped.
+	OnUnrelatedGoFileChange func(filePath string) error
+
+	// Non-Go processes to run alongside your app (e.g., a Tailwind watcher or a mock
+	// API). They start before your app, restart with backoff if they crash, have their
+	// output prefixed with their name, and are terminated when the dev server stops.
+	Sidecars []DevSidecar
 }
 
 type WatchedFile struct {
@@ -118,6 +234,14 @@ type WatchedFile struct {
 	// provide you with a client-side revalidate function, in which case you'd set
 	// `window.__kirunaRevalidate` to that function, and set this field to true.
 	RunClientDefinedRevalidateFunc bool
+
+	// Set on the default watched file for PrivateStaticDir when
+	// DevConfig.HotReloadPrivateFiles is true
+	hotReloadsPrivateFiles bool
+
+	// Set on the default watched file for PublicStaticDir when
+	// DevConfig.HotSwapPublicFiles is true
+	hotSwapsPublicFiles bool
 }
 
 type OnChangeFunc func() error
@@ -126,6 +250,10 @@ type OnChange struct {
 	Strategy         string
 	Func             OnChangeFunc
 	ExcludedPatterns []string // Glob patterns (set relative to Config.RootDir)
+
+	// Optional. Used to identify this callback in logs and in the browser error
+	// overlay if it fails. Defaults to the name of Func.
+	Name string
 }
 
 type WatchedFiles []WatchedFile
@@ -158,6 +286,33 @@ func (c *Config) validateConfig() {
 			}
 			seenDirs[dir] = true
 		}
+
+		for name, entry := range c.ScriptEntries {
+			if name == "" || strings.ContainsAny(name, `/\`) {
+				panic(fmt.Sprintf("invalid script name (%q) in kiruna.Config.ScriptEntries. Names must be non-empty and contain no slashes.", name))
+			}
+			if entry == "" {
+				panic(fmt.Sprintf("empty entry file for script %q in kiruna.Config.ScriptEntries", name))
+			}
+		}
+
+		c.validateSizeBudgets()
+		c.validatePublicCacheControl()
+		c.validateTemplatesConfig()
+	}
+
+	c.validateGoBuildOptions()
+	c.validateGoBinaries()
+
+	if c.PublicPathPrefix != "" && !strings.HasPrefix(c.PublicPathPrefix, "/") {
+		panic(fmt.Sprintf("invalid kiruna.Config.PublicPathPrefix (%q). Must start with a slash.", c.PublicPathPrefix))
+	}
+
+	if c.PublicAssetBaseURL != "" {
+		u, err := url.Parse(c.PublicAssetBaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			panic(fmt.Sprintf("invalid kiruna.Config.PublicAssetBaseURL (%q). Must be an absolute http(s) URL.", c.PublicAssetBaseURL))
+		}
 	}
 }
 
@@ -0,0 +1,238 @@
+package ik
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	cspHeader           = "Content-Security-Policy"
+	cspReportOnlyHeader = "Content-Security-Policy-Report-Only"
+)
+
+type CSPOptions struct {
+	// Sources to add per directive, merged with what Kiruna needs
+	// (e.g., {"img-src": {"https://images.example.com", "data:"}}).
+	// Directives without sources (e.g., {"upgrade-insecure-requests": nil})
+	// are emitted bare.
+	Directives map[string][]string
+
+	// If true, CSPMiddleware generates a fresh nonce for every request, adds it
+	// to script-src and style-src, and makes it available to your handlers via
+	// GetCSPNonce.
+	UseNonce bool
+
+	// If true, CSPMiddleware sets Content-Security-Policy-Report-Only instead
+	// of Content-Security-Policy.
+	ReportOnly bool
+}
+
+type cspNonceCtxKey struct{}
+
+// GetCSPNonce returns the nonce that CSPMiddleware generated for the request,
+// or an empty string if there is none.
+func GetCSPNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(cspNonceCtxKey{}).(string)
+	return nonce
+}
+
+func newCSPNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating CSP nonce: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// GetContentSecurityPolicy builds a CSP from everything Kiruna injects into
+// your pages (inline script and style hashes, the dev refresh server origin,
+// and the CDN origin, if any), merged with opts.Directives. Pass an empty
+// nonce unless you are managing nonces yourself (see CSPMiddleware).
+func (c *Config) GetContentSecurityPolicy(opts *CSPOptions, nonce string) string {
+	if opts == nil {
+		opts = &CSPOptions{}
+	}
+
+	directives := map[string][]string{
+		"default-src": {"'self'"},
+		"script-src":  {"'self'"},
+		"style-src":   {"'self'"},
+		"connect-src": {"'self'"},
+	}
+
+	add := func(directive string, sources ...string) {
+		if _, exists := directives[directive]; !exists && cspFetchDirectives[directive] {
+			// Would otherwise have inherited default-src
+			directives[directive] = []string{"'self'"}
+		}
+		directives[directive] = append(directives[directive], sources...)
+	}
+
+	if hash := c.GetRefreshScriptSha256Hash(); hash != "" {
+		add("script-src", toCSPHashSource(hash))
+	}
+	if !c.ServerOnly {
+		if details, err := c.runtimeCache.publicFileMapDetails.Get(); err == nil && details.Sha256Hash != "" {
+			add("script-src", toCSPHashSource(details.Sha256Hash))
+		}
+		if status, err := c.runtimeCache.criticalCSS.Get(); err == nil && status.sha256Hash != "" {
+			add("style-src", toCSPHashSource(status.sha256Hash))
+		}
+	}
+
+	// The dev proxy serves the refresh socket from the app's own origin
+	if c.getIsDev() && !c.ServerOnly && !c.getUseDevProxy() {
+		if port := c.getRefreshServerPort(); port != 0 {
+			add("connect-src", fmt.Sprintf("ws://localhost:%d", port))
+		}
+	}
+
+	if origin := c.getPublicAssetOrigin(); origin != "" {
+		for _, directive := range []string{"script-src", "style-src", "img-src", "font-src", "connect-src"} {
+			add(directive, origin)
+		}
+	}
+
+	if nonce != "" {
+		add("script-src", "'nonce-"+nonce+"'")
+		add("style-src", "'nonce-"+nonce+"'")
+	}
+
+	for directive, sources := range opts.Directives {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if slices.Contains(sources, "'none'") {
+			directives[directive] = []string{"'none'"}
+			continue
+		}
+		add(directive, sources...)
+	}
+
+	return formatCSP(directives)
+}
+
+// Directives that fall back to default-src when not set
+var cspFetchDirectives = map[string]bool{
+	"child-src":       true,
+	"connect-src":     true,
+	"font-src":        true,
+	"frame-src":       true,
+	"img-src":         true,
+	"manifest-src":    true,
+	"media-src":       true,
+	"object-src":      true,
+	"script-src":      true,
+	"script-src-attr": true,
+	"script-src-elem": true,
+	"style-src":       true,
+	"style-src-attr":  true,
+	"style-src-elem":  true,
+	"worker-src":      true,
+}
+
+// getPublicAssetOrigin returns the origin of Config.PublicAssetBaseURL
+// if it is in effect, and an empty string otherwise.
+func (c *Config) getPublicAssetOrigin() string {
+	if c.publicAssetBaseURL == "" || c.getIsDev() {
+		return ""
+	}
+	u, err := url.Parse(c.publicAssetBaseURL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func toCSPHashSource(base64Hash string) string {
+	return "'sha256-" + base64Hash + "'"
+}
+
+// formatCSP renders directives in a stable order (default-src first, then
+// alphabetical), with duplicate sources removed.
+func formatCSP(directives map[string][]string) string {
+	names := make([]string, 0, len(directives))
+	for name := range directives {
+		if name != "default-src" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := directives["default-src"]; ok {
+		names = append([]string{"default-src"}, names...)
+	}
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		seen := make(map[string]bool, len(directives[name]))
+		part := name
+		for _, source := range directives[name] {
+			if source == "" || seen[source] {
+				continue
+			}
+			seen[source] = true
+			part += " " + source
+		}
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// NonceMiddleware generates a fresh nonce for every request and makes it
+// available to your handlers via GetCSPNonce, without setting any headers.
+// Use it if you build your CSP yourself, and pass the nonce to the
+// "WithNonce" element getters (e.g., GetCriticalCSSStyleElementWithNonce).
+func (c *Config) NonceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, _, err := c.withNewCSPNonce(r)
+		if err != nil {
+			c.Logger.Error(err.Error())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *Config) withNewCSPNonce(r *http.Request) (*http.Request, string, error) {
+	nonce, err := newCSPNonce()
+	if err != nil {
+		return r, "", err
+	}
+	return r.WithContext(context.WithValue(r.Context(), cspNonceCtxKey{}, nonce)), nonce, nil
+}
+
+// CSPMiddleware sets a Content-Security-Policy header (see
+// GetContentSecurityPolicy) on every response.
+func (c *Config) CSPMiddleware(opts *CSPOptions) func(http.Handler) http.Handler {
+	if opts == nil {
+		opts = &CSPOptions{}
+	}
+
+	headerName := cspHeader
+	if opts.ReportOnly {
+		headerName = cspReportOnlyHeader
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var nonce string
+			if opts.UseNonce {
+				var err error
+				if r, nonce, err = c.withNewCSPNonce(r); err != nil {
+					c.Logger.Error(err.Error())
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+			}
+			w.Header().Set(headerName, c.GetContentSecurityPolicy(opts, nonce))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package ik
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGetContentSecurityPolicy(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+	defer resetEnv()
+
+	env.createTestFile(t, "critical.css", "body { color: red; }")
+	env.createTestFile(t, "main.css", "p { font-size: 16px; }")
+	env.config.publicAssetBaseURL = "https://cdn.example.com/assets/"
+
+	if _, err := env.config.Build(false, false); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	csp := env.config.GetContentSecurityPolicy(&CSPOptions{
+		Directives: map[string][]string{
+			"img-src":                   {"data:"},
+			"object-src":                {"'none'"},
+			"upgrade-insecure-requests": nil,
+		},
+	}, "")
+
+	for _, want := range []string{
+		"default-src 'self'; ",
+		"'sha256-" + env.config.GetCriticalCSSStyleElementSha256Hash() + "'",
+		"'sha256-" + env.config.GetPublicFileMapScriptSha256Hash() + "'",
+		"img-src 'self' https://cdn.example.com data:",
+		"object-src 'none'",
+		"; upgrade-insecure-requests",
+	} {
+		if !strings.Contains(csp, want) {
+			t.Errorf("CSP is missing %q:\n%s", want, csp)
+		}
+	}
+	if strings.Contains(csp, "ws://") {
+		t.Errorf("CSP allows the refresh server in prod:\n%s", csp)
+	}
+
+	// In dev, the refresh server is allowed, and the CDN is not used
+	os.Setenv(modeKey, devModeVal)
+	os.Setenv(refreshServerPortKey, "10001")
+	csp = env.config.GetContentSecurityPolicy(nil, "")
+	if !strings.Contains(csp, "connect-src 'self' ws://localhost:10001") {
+		t.Errorf("dev CSP is missing the refresh server origin:\n%s", csp)
+	}
+	if !strings.Contains(csp, "'sha256-"+env.config.GetRefreshScriptSha256Hash()+"'") {
+		t.Errorf("dev CSP is missing the refresh script hash:\n%s", csp)
+	}
+	if strings.Contains(csp, "cdn.example.com") {
+		t.Errorf("dev CSP includes the CDN origin:\n%s", csp)
+	}
+}
+
+func TestCSPMiddlewareNonce(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.config.ServerOnly = true
+
+	var nonce string
+	handler := env.config.CSPMiddleware(&CSPOptions{UseNonce: true, ReportOnly: true})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nonce = GetCSPNonce(r)
+		}),
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if nonce == "" {
+		t.Fatalf("GetCSPNonce() = empty, want nonce")
+	}
+	csp := rec.Header().Get(cspReportOnlyHeader)
+	if !strings.Contains(csp, "script-src 'self' 'nonce-"+nonce+"'") {
+		t.Errorf("CSP is missing the nonce:\n%s", csp)
+	}
+
+	firstNonce := nonce
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if nonce == firstNonce || strings.Contains(rec.Header().Get(cspReportOnlyHeader), firstNonce) {
+		t.Errorf("nonce was reused across requests")
+	}
+}
+
+func TestNonceElementVariants(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+	defer resetEnv()
+
+	env.createTestFile(t, "critical.css", "body { color: red; }")
+	env.createTestFile(t, "main.css", "p { font-size: 16px; }")
+
+	if _, err := env.config.Build(false, false); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	const nonce = "abc123"
+
+	styleEl := string(env.config.GetCriticalCSSStyleElementWithNonce(nonce))
+	if !strings.Contains(styleEl, `nonce="abc123"`) || !strings.Contains(styleEl, env.config.GetCriticalCSS()) {
+		t.Errorf("GetCriticalCSSStyleElementWithNonce() = %v", styleEl)
+	}
+	if strings.Contains(styleEl, "sha256-") {
+		t.Errorf("GetCriticalCSSStyleElementWithNonce() has a hash integrity: %v", styleEl)
+	}
+	if got := env.config.GetCriticalCSSStyleElementWithNonce(""); got != env.config.GetCriticalCSSStyleElement() {
+		t.Errorf("GetCriticalCSSStyleElementWithNonce(\"\") = %v, want hash variant", got)
+	}
+
+	fileMapEls := string(env.config.GetPublicFileMapElementsWithNonce(nonce))
+	if strings.Count(fileMapEls, `nonce="abc123"`) != 2 || !strings.Contains(fileMapEls, "kirunaPublicFileMap") {
+		t.Errorf("GetPublicFileMapElementsWithNonce() = %v", fileMapEls)
+	}
+
+	if got := env.config.GetRefreshScriptWithNonce(nonce); got != "" {
+		t.Errorf("GetRefreshScriptWithNonce() in prod = %v, want empty", got)
+	}
+	os.Setenv(modeKey, devModeVal)
+	if got := string(env.config.GetRefreshScriptWithNonce(nonce)); !strings.Contains(got, `<script nonce="abc123">`) {
+		t.Errorf("GetRefreshScriptWithNonce() in dev = %v", got)
+	}
+}
+
+func TestNonceMiddleware(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	var nonce string
+	handler := env.config.NonceMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = GetCSPNonce(r)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if nonce == "" {
+		t.Errorf("GetCSPNonce() = empty, want nonce")
+	}
+	if rec.Header().Get(cspHeader) != "" {
+		t.Errorf("NonceMiddleware() set a CSP header")
+	}
+}
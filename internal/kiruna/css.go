@@ -26,18 +26,27 @@ func (c *Config) getInitialStyleSheetLinkElement() (*template.HTML, error) {
 		sb.WriteString(url)
 		sb.WriteString(`" id="`)
 		sb.WriteString(StyleSheetElementID)
-		sb.WriteString(`" />`)
+		sb.WriteString(`"`)
+
+		if integrity, err := c.getStyleSheetIntegrity(); err != nil {
+			c.Logger.Error(fmt.Sprintf("error getting normal CSS integrity: %v", err))
+		} else {
+			sb.WriteString(` integrity="`)
+			sb.WriteString(integrity)
+			sb.WriteString(`" crossorigin="anonymous"`)
+		}
+
+		sb.WriteString(` />`)
 		result = template.HTML(sb.String())
 	}
 
 	return &result, nil
 }
 
-func (c *Config) getInitialStyleSheetURL() (string, error) {
+func (c *Config) readNormalCSSFileRef() (string, error) {
 	baseFS, err := c.GetBaseFS()
 	if err != nil {
-		c.Logger.Error(fmt.Sprintf("error getting FS: %v", err))
-		return "", err
+		return "", fmt.Errorf("error getting FS: %v", err)
 	}
 
 	distKirunaInternal := c.__dist.S().Kiruna.S().Internal
@@ -48,11 +57,27 @@ func (c *Config) getInitialStyleSheetURL() (string, error) {
 		distKirunaInternal.S().NormalCSSFileRefDotTXT.LastSegment(),
 	))
 	if err != nil {
-		c.Logger.Error(fmt.Sprintf("error reading normal CSS URL: %v", err))
+		return "", fmt.Errorf("error reading normal CSS URL: %v", err)
+	}
+
+	return string(content), nil
+}
+
+func (c *Config) getInitialStyleSheetURL() (string, error) {
+	fileName, err := c.readNormalCSSFileRef()
+	if err != nil {
+		c.Logger.Error(err.Error())
 		return "", err
 	}
+	return c.getPublicURLBase() + fileName, nil
+}
 
-	return "/" + filepath.Join(PUBLIC, string(content)), nil
+func (c *Config) getStyleSheetIntegrity() (string, error) {
+	fileName, err := c.readNormalCSSFileRef()
+	if err != nil {
+		return "", err
+	}
+	return c.getPublicDistFileIntegrity(fileName)
 }
 
 func (c *Config) GetStyleSheetLinkElement() template.HTML {
@@ -139,3 +164,27 @@ func (c *Config) GetCriticalCSSStyleElementSha256Hash() string {
 	result, _ := c.runtimeCache.criticalCSS.Get()
 	return result.sha256Hash
 }
+
+// GetCriticalCSSStyleElementWithNonce is like GetCriticalCSSStyleElement, but
+// renders a nonce attribute instead of relying on a CSP hash. If nonce is
+// empty, it returns the same element as GetCriticalCSSStyleElement.
+func (c *Config) GetCriticalCSSStyleElementWithNonce(nonce string) template.HTML {
+	if nonce == "" {
+		return c.GetCriticalCSSStyleElement()
+	}
+	result, _ := c.runtimeCache.criticalCSS.Get()
+	if result.noSuchFile {
+		return ""
+	}
+	el, err := htmlutil.RenderElement(&htmlutil.Element{
+		Tag:               "style",
+		Attributes:        map[string]string{"nonce": nonce},
+		TrustedAttributes: map[string]string{"id": CriticalCSSElementID},
+		InnerHTML:         template.HTML(result.codeStr),
+	})
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error rendering element: %v", err))
+		return ""
+	}
+	return el
+}
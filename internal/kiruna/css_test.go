@@ -66,10 +66,13 @@ func TestGetStyleSheetLinkElement(t *testing.T) {
 	defer teardownTestEnv(t)
 
 	normalCSSFile := "normal_1234567890.css"
+	normalCSSContent := "body { color: red; }"
 	env.createTestFile(t, "dist/kiruna/internal/normal_css_file_ref.txt", normalCSSFile)
+	env.createTestFile(t, "dist/kiruna/static/public/"+normalCSSFile, normalCSSContent)
 
 	result := env.config.GetStyleSheetLinkElement()
-	expected := template.HTML(`<link rel="stylesheet" href="/public/` + normalCSSFile + `" id="` + StyleSheetElementID + `" />`)
+	expected := template.HTML(`<link rel="stylesheet" href="/public/` + normalCSSFile + `" id="` + StyleSheetElementID +
+		`" integrity="` + getSRIDigest([]byte(normalCSSContent)) + `" crossorigin="anonymous" />`)
 	if result != expected {
 		t.Errorf("GetStyleSheetLinkElement() = %v, want: %v", result, expected)
 	}
@@ -14,6 +14,7 @@ type debouncer struct {
 	events   []fsnotify.Event
 	duration time.Duration
 	callback func(events []fsnotify.Event)
+	stopped  bool
 }
 
 func newDebouncer(duration time.Duration, callback func(events []fsnotify.Event)) *debouncer {
@@ -27,6 +28,10 @@ func (d *debouncer) addEvent(event fsnotify.Event) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	if d.stopped {
+		return
+	}
+
 	d.events = append(d.events, event)
 
 	if d.timer != nil {
@@ -37,9 +42,23 @@ func (d *debouncer) addEvent(event fsnotify.Event) {
 		d.mu.Lock()
 		defer d.mu.Unlock()
 
-		if len(d.events) > 0 {
+		if !d.stopped && len(d.events) > 0 {
 			d.callback(d.events)
 			d.events = nil
 		}
 	})
 }
+
+// stop drops any pending events and prevents further callbacks. Because
+// the callback runs while holding the lock, stop also waits for any
+// in-flight callback to return.
+func (d *debouncer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stopped = true
+	d.events = nil
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
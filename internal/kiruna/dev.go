@@ -1,153 +1,125 @@
 package ik
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"net/http"
 	"os"
 	"os/exec"
-	"path/filepath"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/sjc5/kit/pkg/grace"
-	"github.com/sjc5/kit/pkg/port"
 	"golang.org/x/sync/errgroup"
 )
 
 const (
+	appStopTimeout = 5 * time.Second
+
 	healthCheckWarningA = `WARNING: No healthcheck endpoint found, setting to "/".`
 	healthCheckWarningB = `To set this explicitly, use the "HealthcheckEndpoint" field in your dev config.`
 	healthCheckWarning  = healthCheckWarningA + "\n" + healthCheckWarningB
 )
 
 func (c *Config) MustStartDev(devConfig *DevConfig) {
-	enforceProperInstantiation(c)
-
-	// Short circuit if no dev config
-	if devConfig == nil {
-		errMsg := "error: no dev config found"
-		c.Logger.Error(errMsg)
-		panic(errMsg)
-	}
-
-	c.devConfig = devConfig
-	c.cleanWatchRoot = filepath.Clean(c.devConfig.WatchRoot)
-
-	if len(c.devConfig.HealthcheckEndpoint) == 0 {
-		c.Logger.Warn(healthCheckWarning)
-		c.devConfig.HealthcheckEndpoint = "/"
-	}
-
-	SetModeToDev()
-
-	c.devInitOnce()
-
-	// take a breather for prior process to clean up
-	// not sure why needed, but it allows same port to be used
-	time.Sleep(10 * time.Millisecond)
-
-	// Warm port right away, in case default is unavailable
-	// Also, env needs to be set in this scope
-	MustGetPort()
-
-	// Set refresh server port
-	if freePort, err := port.GetFreePort(defaultFreePort); err == nil {
-		setRefreshServerPort(freePort)
-	} else {
-		c.Logger.Error(fmt.Sprintf("error: failed to get free port for refresh server: %v", err))
-		panic(err)
-	}
-
-	err := c.Build(false, false)
+	server, err := c.StartDev(context.Background(), devConfig)
 	if err != nil {
-		errMsg := fmt.Sprintf("error: failed to build app: %v", err)
-		c.Logger.Error(errMsg)
-		panic(errMsg)
-	}
-
-	if c.ServerOnly {
-		c.mustSetupWatcher()
-		return
+		c.Logger.Error(err.Error())
+		panic(err)
 	}
-
-	c.Logger.Info("Initializing sidecar refresh server", "port", getRefreshServerPort())
-
-	go c.manager.start()
-	go c.mustSetupWatcher()
-
-	mux := http.NewServeMux()
-
-	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		websocketHandler(c.manager)(w, r)
-	})
-
-	mux.HandleFunc("/get-refresh-script-inner", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Content-Type", "text/javascript")
-		w.Write([]byte(GetRefreshScriptInner(getRefreshServerPort())))
-	})
-
-	if err := http.ListenAndServe(":"+strconv.Itoa(getRefreshServerPort()), mux); err != nil {
-		errMsg := fmt.Sprintf("error: failed to start refresh server: %v", err)
-		c.Logger.Error(errMsg)
-		panic(errMsg)
+	if err := server.Wait(); err != nil {
+		panic(err)
 	}
 }
 
-func (c *Config) mustKillAppDev() {
+func (c *Config) killAppDev() error {
 	c.lastBuildCmd.mu.Lock()
 	defer c.lastBuildCmd.mu.Unlock()
 
-	if c.lastBuildCmd.v != nil {
-		if err := grace.TerminateProcess(c.lastBuildCmd.v.Process, 5*time.Second, c.Logger); err != nil {
-			errMsg := fmt.Sprintf(
-				"error: failed to kill running app with pid %d: %v",
-				c.lastBuildCmd.v.Process.Pid,
-				err,
-			)
-			c.Logger.Error(errMsg)
-			panic(errMsg)
-		} else {
-			c.Logger.Info("Terminated previous process", "pid", c.lastBuildCmd.v.Process.Pid)
+	if c.lastBuildCmd.v == nil {
+		return nil
+	}
+	cmd, done := c.lastBuildCmd.v, c.lastBuildDone
+	c.lastBuildCmd.v, c.lastBuildDone = nil, nil
 
-			c.lastBuildCmd.v = nil
+	select {
+	case <-done:
+		return nil
+	default:
+	}
+
+	// The process is reaped by waitForAppExit, so any error here is only
+	// worth reporting if it's still running
+	err := grace.TerminateProcess(cmd.Process, appStopTimeout, c.Logger)
+	select {
+	case <-done:
+		c.Logger.Info("Terminated previous process", "pid", cmd.Process.Pid)
+	case <-time.After(appStopTimeout):
+		c.Logger.Error(fmt.Sprintf("error: failed to terminate running app with pid %d: %v", cmd.Process.Pid, err))
+		if err := cmd.Process.Kill(); err != nil {
+			errMsg := fmt.Sprintf("error: failed to kill running app with pid %d: %v", cmd.Process.Pid, err)
+			c.Logger.Error(errMsg)
+			return errors.New(errMsg)
 		}
+		<-done
 	}
+	return nil
 }
 
-func (c *Config) mustStartAppDev() {
+func (c *Config) startAppDev() error {
 	c.lastBuildCmd.mu.Lock()
 	defer c.lastBuildCmd.mu.Unlock()
 
-	buildDest := c.__dist.S().Bin.S().Main.FullPath()
+	buildDest := c.getGoBinaryPath("", nil)
+
+	// Prefixed like every other process the dev loop runs (see GoBinary)
+	prefix := "[" + mainBinaryName + "] "
+	stdout, stderr := newPrefixWriter(os.Stdout, prefix), newPrefixWriter(os.Stderr, prefix)
+	cmd := exec.Command(buildDest)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
-	c.lastBuildCmd.v = exec.Command(buildDest)
-	c.lastBuildCmd.v.Stdout = os.Stdout
-	c.lastBuildCmd.v.Stderr = os.Stderr
+	cmd.Env = c.getAppEnvDev()
 
-	if err := c.lastBuildCmd.v.Start(); err != nil {
+	if err := cmd.Start(); err != nil {
 		errMsg := fmt.Sprintf("error: failed to start app: %v", err)
 		c.Logger.Error(errMsg)
-		panic(errMsg)
+		return errors.New(errMsg)
 	}
 
-	c.Logger.Info("App is running", "pid", c.lastBuildCmd.v.Process.Pid)
+	done := make(chan struct{})
+	c.lastBuildCmd.v, c.lastBuildDone = cmd, done
+	go waitForAppExit(cmd, stdout, stderr, done)
+
+	c.Logger.Info("App is running", "pid", cmd.Process.Pid)
+	return nil
 }
 
-func (c *Config) mustHandleWatcherEmissions() {
-	debouncer := newDebouncer(30*time.Millisecond, func(events []fsnotify.Event) {
-		c.processBatchedEvents(events)
-	})
+// waitForAppExit reaps the app and flushes its output (so that a trailing
+// partial line isn't swallowed) before closing done.
+func waitForAppExit(cmd *exec.Cmd, stdout, stderr *prefixWriter, done chan struct{}) {
+	cmd.Wait()
+	stdout.flush()
+	stderr.flush()
+	close(done)
+}
 
+func (c *Config) handleWatcherEmissions(ctx context.Context, debouncer *debouncer) {
 	for {
 		select {
-		case evt := <-c.watcher.Events:
+		case <-ctx.Done():
+			return
+		case evt, ok := <-c.watcher.Events:
+			if !ok {
+				return
+			}
 			debouncer.addEvent(evt)
-		case err := <-c.watcher.Errors:
+		case err, ok := <-c.watcher.Errors:
+			if !ok {
+				return
+			}
 			c.Logger.Error(fmt.Sprintf("watcher error: %v", err))
 		}
 	}
@@ -178,6 +150,9 @@ func (c *Config) processBatchedEvents(events []fsnotify.Event) {
 
 		evtDetails := c.getEvtDetails(evt)
 		if evtDetails.isIgnored {
+			if evtDetails.isUnrelatedGo && !evtDetails.isNonEmptyCHMODOnly {
+				c.handleUnrelatedGoFileChange(evt.Name)
+			}
 			continue
 		}
 
@@ -186,11 +161,17 @@ func (c *Config) processBatchedEvents(events []fsnotify.Event) {
 			wfc = c.defaultWatchedFile
 		}
 
-		if _, alreadyHandled := wfcsAlreadyHandled[wfc.Pattern]; alreadyHandled {
+		// Go changes are handled once per set of affected binaries
+		handledKey := wfc.Pattern
+		if evtDetails.isGo || len(evtDetails.goBinaries) > 0 {
+			handledKey += fmt.Sprintf("\x00%t:%s", evtDetails.isGo, strings.Join(evtDetails.goBinaries, ","))
+		}
+
+		if _, alreadyHandled := wfcsAlreadyHandled[handledKey]; alreadyHandled {
 			continue
 		}
 
-		wfcsAlreadyHandled[wfc.Pattern] = true
+		wfcsAlreadyHandled[handledKey] = true
 
 		if !isGoOrNeedsHardReloadEvenIfNonGo {
 			isGoOrNeedsHardReloadEvenIfNonGo = evtDetails.isGo
@@ -234,17 +215,16 @@ func (c *Config) processBatchedEvents(events []fsnotify.Event) {
 			return
 		}
 
-		c.manager.broadcast <- refreshFilePayload{
+		c.manager.broadcastPayload(refreshFilePayload{
 			ChangeType: changeTypeRebuilding,
-		}
+		})
 	}
 
 	eg := errgroup.Group{}
 	if hasMultipleEvents && isGoOrNeedsHardReloadEvenIfNonGo {
 		eg.Go(func() error {
 			c.Logger.Info("Shutting down running app")
-			c.mustKillAppDev()
-			return nil
+			return c.killAppDev()
 		})
 	}
 
@@ -254,6 +234,7 @@ func (c *Config) processBatchedEvents(events []fsnotify.Event) {
 		err := c.mustHandleFileChange(evtDetails, hasMultipleEvents)
 		if err != nil {
 			c.Logger.Error(fmt.Sprintf("error: failed to handle file change: %v", err))
+			c.broadcastBuildError(err)
 			return
 		}
 	}
@@ -264,12 +245,28 @@ func (c *Config) processBatchedEvents(events []fsnotify.Event) {
 			return
 		}
 		c.Logger.Info("Restarting app")
-		c.mustStartAppDev()
+		if err := c.startAppDev(); err != nil {
+			c.broadcastBuildError(err)
+			return
+		}
 	}
 
 	if hasMultipleEvents {
 		c.Logger.Info("Hard reloading browser")
-		c.mustReloadBroadcast(refreshFilePayload{ChangeType: changeTypeOther})
+		if err := c.reloadBroadcast(refreshFilePayload{ChangeType: changeTypeOther}); err != nil {
+			c.Logger.Error(err.Error())
+			c.broadcastBuildError(err)
+		}
+	}
+}
+
+func (c *Config) handleUnrelatedGoFileChange(filePath string) {
+	if c.devConfig.OnUnrelatedGoFileChange == nil {
+		c.Logger.Info("Ignoring Go file outside of app import graph", "filename", filePath)
+		return
+	}
+	if err := c.devConfig.OnUnrelatedGoFileChange(filePath); err != nil {
+		c.Logger.Error(fmt.Sprintf("error in OnUnrelatedGoFileChange for %s: %v", filePath, err))
 	}
 }
 
@@ -286,10 +283,17 @@ func (c *Config) mustHandleFileChange(
 		wfc = c.defaultWatchedFile
 	}
 
+	// Neither the app nor the browser need to know about changes that only
+	// affect auxiliary binaries
+	if !evtDetails.isGo && len(evtDetails.goBinaries) > 0 && evtDetails.wfc == nil {
+		defer c.invalidateGoDepGraph()
+		return c.rebuildGoBinariesDev(evtDetails.goBinaries)
+	}
+
 	if !c.ServerOnly && !wfc.SkipRebuildingNotification && !evtDetails.isKirunaCSS && !isPartOfBatch {
-		c.manager.broadcast <- refreshFilePayload{
+		c.manager.broadcastPayload(refreshFilePayload{
 			ChangeType: changeTypeRebuilding,
-		}
+		})
 	}
 
 	needsHardReloadEvenIfNonGo := getNeedsHardReloadEvenIfNonGo(wfc)
@@ -304,11 +308,28 @@ func (c *Config) mustHandleFileChange(
 	if needsKillAndRestart {
 		killAndRestartEG.Go(func() error {
 			c.Logger.Info("Terminating running app")
-			c.mustKillAppDev()
-			return nil
+			return c.killAppDev()
 		})
 	}
 
+	// Needed to tell the browser which URL a swapped public asset replaces
+	var oldPublicFileMap FileMap
+	if wfc.hotSwapsPublicFiles && !c.ServerOnly && !isPartOfBatch {
+		var err error
+		if oldPublicFileMap, err = c.loadMapFromGobIfExists(PublicFileMapGobName); err != nil {
+			c.Logger.Error(fmt.Sprintf("error reading public file map: %v", err))
+		}
+	}
+
+	// Needed to tell the browser which bundle URLs the new ones replace
+	var oldScriptOutputs map[string]fileVal
+	if evtDetails.isKirunaScript && !needsHardReloadEvenIfNonGo && !c.ServerOnly && !isPartOfBatch {
+		var err error
+		if oldScriptOutputs, err = c.loadScriptsFileRef(); err != nil {
+			c.Logger.Error(fmt.Sprintf("error reading scripts file ref: %v", err))
+		}
+	}
+
 	sortedOnChanges := sortOnChangeCallbacks(wfc.OnChangeCallbacks)
 
 	if sortedOnChanges.exists {
@@ -355,13 +376,19 @@ func (c *Config) mustHandleFileChange(
 		return err
 	}
 
+	if wfc.hotReloadsPrivateFiles {
+		c.notifyAppOfPrivateFilesChangeDev()
+	}
+
 	if needsKillAndRestart {
 		if err := killAndRestartEG.Wait(); err != nil {
 			c.Logger.Error(fmt.Sprintf("error: failed to kill app: %v", err))
 			return err
 		}
 		c.Logger.Info("Restarting app")
-		c.mustStartAppDev()
+		if err := c.startAppDev(); err != nil {
+			return err
+		}
 	}
 
 	if c.ServerOnly || isPartOfBatch {
@@ -370,14 +397,26 @@ func (c *Config) mustHandleFileChange(
 
 	if wfc.RunClientDefinedRevalidateFunc {
 		c.Logger.Info("Revalidating browser")
-		c.mustReloadBroadcast(refreshFilePayload{ChangeType: changeTypeRevalidate})
-		return nil
+		return c.reloadBroadcast(refreshFilePayload{ChangeType: changeTypeRevalidate})
+	}
+
+	if wfc.hotSwapsPublicFiles {
+		if rfp := c.getPublicAssetSwapPayload(evtDetails.evt.Name, oldPublicFileMap); rfp != nil {
+			c.Logger.Info("Hot swapping public asset in browser")
+			return c.reloadBroadcast(*rfp)
+		}
+	}
+
+	if evtDetails.isKirunaScript && !needsHardReloadEvenIfNonGo {
+		if rfp := c.getHMRPayload(oldScriptOutputs); rfp != nil {
+			c.Logger.Info("Sending module updates to browser")
+			return c.reloadBroadcast(*rfp)
+		}
 	}
 
 	if !evtDetails.isKirunaCSS || needsHardReloadEvenIfNonGo {
 		c.Logger.Info("Hard reloading browser")
-		c.mustReloadBroadcast(refreshFilePayload{ChangeType: changeTypeOther})
-		return nil
+		return c.reloadBroadcast(refreshFilePayload{ChangeType: changeTypeOther})
 	}
 	// At this point, we know it's a CSS file
 
@@ -387,19 +426,24 @@ func (c *Config) mustHandleFileChange(
 	}
 
 	c.Logger.Info("Hot reloading browser")
-	c.mustReloadBroadcast(refreshFilePayload{
+	return c.reloadBroadcast(refreshFilePayload{
 		ChangeType: cssType,
 
 		// These must be called AFTER ProcessCSS
 		CriticalCSS:  base64.StdEncoding.EncodeToString([]byte(c.GetCriticalCSS())),
 		NormalCSSURL: c.GetStyleSheetURL(),
 	})
-
-	return nil
 }
 
 func (c *Config) callback(wfc *WatchedFile, evtDetails *EvtDetails) error {
-	if evtDetails.isGo {
+	if evtDetails.isGo || len(evtDetails.goBinaries) > 0 {
+		defer c.invalidateGoDepGraph()
+		if err := c.rebuildGoBinariesDev(evtDetails.goBinaries); err != nil {
+			return err
+		}
+		if !evtDetails.isGo {
+			return c.runOtherFileBuild(wfc)
+		}
 		return c.compileBinary()
 	}
 
@@ -412,21 +456,41 @@ func (c *Config) callback(wfc *WatchedFile, evtDetails *EvtDetails) error {
 		} else {
 			c.processCSSNormal()
 		}
+		c.reportSizeBudgetsDev()
+	}
+
+	if evtDetails.isKirunaScript && !getNeedsHardReloadEvenIfNonGo(wfc) {
+		return c.processScripts()
+	}
+
+	if wfc.hotReloadsPrivateFiles {
+		return c.hotReloadPrivateFiles()
 	}
 
 	return c.runOtherFileBuild(wfc)
 }
 
+// hotReloadPrivateFiles does the part of a build that private file changes
+// affect, without touching anything the running app has loaded.
+func (c *Config) hotReloadPrivateFiles() error {
+	if err := c.copyPrivateFiles(true); err != nil {
+		return fmt.Errorf("error copying private files: %v", err)
+	}
+	if err := c.validateTemplates(); err != nil {
+		return fmt.Errorf("error validating templates: %w", err)
+	}
+	return nil
+}
+
 // This is different than inside of handleGoFileChange, because here we
 // assume we need to re-run other build steps too, not just recompile Go.
 // Also, we don't necessarily recompile Go here (we only necessarily) run
 // the other build steps. We only recompile Go if wfc.RecompileBinary is true.
 func (c *Config) runOtherFileBuild(wfc *WatchedFile) error {
-	err := c.Build(wfc.RecompileBinary, true)
+	_, err := c.Build(wfc.RecompileBinary, true)
 	if err != nil {
-		msg := fmt.Sprintf("error: failed to build app: %v", err)
-		c.Logger.Error(msg)
-		return errors.New(msg)
+		c.Logger.Error(fmt.Sprintf("error: failed to build app: %v", err))
+		return fmt.Errorf("error: failed to build app: %w", err)
 	}
 	return nil
 }
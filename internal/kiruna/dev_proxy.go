@@ -0,0 +1,111 @@
+package ik
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	devProxyEventsPath        = "/__kiruna/events"
+	devProxyRefreshScriptPath = "/__kiruna/refresh-script.js"
+)
+
+var devProxyRefreshScriptTag = []byte(`<script src="` + devProxyRefreshScriptPath + `"></script>`)
+
+// getAppPortDev returns the port the app process actually listens on in dev.
+// When the dev proxy is in use, this is an internal port sitting behind the
+// proxy, which itself listens on the public port.
+func (c *Config) getAppPortDev() int {
+	if c.appPortBehindProxy != 0 {
+		return c.appPortBehindProxy
+	}
+	return c.port
+}
+
+func (c *Config) newDevProxyMux() (*http.ServeMux, error) {
+	target, err := url.Parse("http://localhost:" + strconv.Itoa(c.appPortBehindProxy))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing dev proxy target URL: %v", err)
+	}
+
+	injectRefreshScript := c.devConfig.InjectRefreshScript
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	baseDirector := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		baseDirector(r)
+		if injectRefreshScript {
+			// We need to be able to read (and rewrite) HTML bodies
+			r.Header.Del("Accept-Encoding")
+		}
+	}
+
+	if injectRefreshScript {
+		proxy.ModifyResponse = injectDevProxyRefreshScript
+	}
+
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		c.Logger.Warn(fmt.Sprintf("dev proxy: app not reachable: %v", err))
+		http.Error(w, "Kiruna dev proxy: app not reachable (it may be restarting)", http.StatusBadGateway)
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(devProxyEventsPath, websocketHandler(c.manager))
+	mux.HandleFunc(devAppEventsPath, c.appEvents.handler)
+
+	mux.HandleFunc(devProxyRefreshScriptPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte(getRefreshScriptInnerSameOrigin()))
+	})
+
+	mux.Handle("/", proxy)
+
+	return mux, nil
+}
+
+func injectDevProxyRefreshScript(resp *http.Response) error {
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil
+	}
+	if resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading HTML response body: %v", err)
+	}
+	resp.Body.Close()
+
+	body = insertBeforeClosingBodyTag(body, devProxyRefreshScriptTag)
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.Header.Del("ETag")
+
+	return nil
+}
+
+// insertBeforeClosingBodyTag inserts snippet right before the last
+// closing body tag, or appends it if there is no such tag.
+func insertBeforeClosingBodyTag(body, snippet []byte) []byte {
+	idx := bytes.LastIndex(bytes.ToLower(body), []byte("</body>"))
+	if idx == -1 {
+		return append(body, snippet...)
+	}
+	result := make([]byte, 0, len(body)+len(snippet))
+	result = append(result, body[:idx]...)
+	result = append(result, snippet...)
+	result = append(result, body[idx:]...)
+	return result
+}
@@ -0,0 +1,59 @@
+package ik
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInsertBeforeClosingBodyTag(t *testing.T) {
+	snippet := []byte("<x>")
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"WithBody", "<html><body>hi</body></html>", "<html><body>hi<x></body></html>"},
+		{"UpperCase", "<HTML><BODY>hi</BODY></HTML>", "<HTML><BODY>hi<x></BODY></HTML>"},
+		{"NoBody", "<p>hi</p>", "<p>hi</p><x>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(insertBeforeClosingBodyTag([]byte(tt.body), snippet)); got != tt.want {
+				t.Errorf("insertBeforeClosingBodyTag() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInjectDevProxyRefreshScript(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		wantInject  bool
+	}{
+		{"HTML", "text/html; charset=utf-8", true},
+		{"JSON", "application/json", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rec.Header().Set("Content-Type", tt.contentType)
+			rec.WriteString("<body></body>")
+			resp := rec.Result()
+
+			if err := injectDevProxyRefreshScript(resp); err != nil {
+				t.Fatalf("injectDevProxyRefreshScript() error = %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			injected := strings.Contains(string(body), devProxyRefreshScriptPath)
+			if injected != tt.wantInject {
+				t.Errorf("injected = %v, want %v (body: %s)", injected, tt.wantInject, body)
+			}
+			if tt.wantInject && resp.ContentLength != int64(len(body)) {
+				t.Errorf("ContentLength = %d, want %d", resp.ContentLength, len(body))
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package ik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sjc5/kit/pkg/port"
+)
+
+const devServerShutdownTimeout = 5 * time.Second
+
+// DevServer is a handle to a running dev loop started with StartDev.
+type DevServer struct {
+	c       *Config
+	cancel  context.CancelFunc
+	done    chan struct{}
+	errOnce sync.Once
+	err     error
+}
+
+// Stop cancels the dev loop and blocks until it has fully shut down.
+func (s *DevServer) Stop() error {
+	s.cancel()
+	return s.Wait()
+}
+
+// Wait blocks until the dev loop has fully shut down, and returns the
+// error that caused it to stop (if any). Stopping via context
+// cancellation or Stop is not considered an error.
+func (s *DevServer) Wait() error {
+	<-s.done
+	return s.err
+}
+
+// Done returns a channel that is closed once the dev loop has fully shut down.
+func (s *DevServer) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *DevServer) fail(err error) {
+	s.errOnce.Do(func() {
+		s.c.Logger.Error(err.Error())
+		s.err = err
+	})
+	s.cancel()
+}
+
+// StartDev builds the project, starts the app, the file watcher, and (unless
+// ServerOnly is set) the refresh server or dev proxy, and then returns. The
+// dev loop runs until ctx is cancelled, Stop is called, or a fatal error occurs.
+func (c *Config) StartDev(ctx context.Context, devConfig *DevConfig) (*DevServer, error) {
+	enforceProperInstantiation(c)
+
+	// Short circuit if no dev config
+	if devConfig == nil {
+		return nil, errors.New("error: no dev config found")
+	}
+
+	if !c.devIsRunning.CompareAndSwap(false, true) {
+		return nil, errors.New("error: dev server is already running for this Kiruna instance")
+	}
+
+	server, err := c.startDev(ctx, devConfig)
+	if err != nil {
+		c.devIsRunning.Store(false)
+		return nil, err
+	}
+	return server, nil
+}
+
+func (c *Config) startDev(ctx context.Context, devConfig *DevConfig) (_ *DevServer, err error) {
+	c.devConfig = cloneDevConfig(devConfig)
+	c.cleanWatchRoot = filepath.Clean(c.devConfig.WatchRoot)
+
+	if err := validateSidecars(c.devConfig.Sidecars); err != nil {
+		return nil, err
+	}
+
+	if len(c.devConfig.HealthcheckEndpoint) == 0 {
+		c.Logger.Warn(healthCheckWarning)
+		c.devConfig.HealthcheckEndpoint = "/"
+	}
+
+	c.setModeToDev()
+
+	if err := c.devInit(); err != nil {
+		return nil, err
+	}
+
+	// A failed start must not leak the watcher devInit created, as StartDev
+	// can be retried
+	defer func() {
+		if err != nil {
+			c.watcher.Close()
+		}
+	}()
+
+	// take a breather for prior process to clean up
+	// not sure why needed, but it allows same port to be used
+	time.Sleep(10 * time.Millisecond)
+
+	// Warm port right away, in case default is unavailable
+	appPort, err := port.GetFreePort(getPort())
+	if err != nil {
+		return nil, fmt.Errorf("error: failed to get free port: %w", err)
+	}
+	c.port = appPort
+
+	useProxy := c.devConfig.UseProxy && !c.ServerOnly
+
+	c.appPortBehindProxy = 0
+	c.refreshServerPort = 0
+	c.useDevProxy = useProxy
+	c.devProxyInjectsScript = useProxy && c.devConfig.InjectRefreshScript
+
+	if useProxy {
+		// The proxy takes over the public port, so the app needs its own
+		freePort, err := port.GetFreePort(c.port + 1)
+		if err != nil {
+			return nil, fmt.Errorf("error: failed to get free port for app behind dev proxy: %w", err)
+		}
+		c.appPortBehindProxy = freePort
+	} else if !c.ServerOnly {
+		// Set refresh server port
+		freePort, err := port.GetFreePort(defaultFreePort)
+		if err != nil {
+			return nil, fmt.Errorf("error: failed to get free port for refresh server: %w", err)
+		}
+		c.refreshServerPort = freePort
+	}
+
+	if _, err := c.Build(false, false); err != nil {
+		return nil, fmt.Errorf("error: failed to build app: %w", err)
+	}
+
+	if err := c.setupWatcher(); err != nil {
+		return nil, err
+	}
+
+	var httpServer *http.Server
+	var listener net.Listener
+
+	if !c.ServerOnly {
+		var handler http.Handler
+		var listenPort int
+
+		if useProxy {
+			mux, err := c.newDevProxyMux()
+			if err != nil {
+				return nil, err
+			}
+			handler, listenPort = mux, c.port
+			c.Logger.Info("Initializing dev proxy", "port", listenPort, "appPort", c.appPortBehindProxy)
+		} else {
+			handler, listenPort = c.newRefreshServerMux(), c.refreshServerPort
+			c.Logger.Info("Initializing sidecar refresh server", "port", listenPort)
+		}
+
+		listener, err = net.Listen("tcp", ":"+strconv.Itoa(listenPort))
+		if err != nil {
+			return nil, fmt.Errorf("error: failed to start refresh server: %w", err)
+		}
+		httpServer = &http.Server{Handler: handler}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	c.devCtx = ctx
+
+	s := &DevServer{c: c, cancel: cancel, done: make(chan struct{})}
+
+	managerStop := make(chan struct{})
+	go c.manager.start(managerStop)
+
+	if httpServer != nil {
+		go func() {
+			if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.fail(fmt.Errorf("error: refresh server failed: %w", err))
+			}
+		}()
+	}
+
+	debouncer := newDebouncer(30*time.Millisecond, func(events []fsnotify.Event) {
+		c.processBatchedEvents(events)
+	})
+
+	var watcherWG sync.WaitGroup
+	watcherWG.Add(1)
+	go func() {
+		defer watcherWG.Done()
+
+		c.startSidecarsDev()
+		if err := c.killAppDev(); err != nil {
+			s.fail(err)
+			return
+		}
+		if err := c.compileBinary(); err != nil {
+			c.Logger.Error(fmt.Sprintf("error: failed to build app: %v", err))
+		}
+		c.startGoBinariesDev()
+		if err := c.startAppDev(); err != nil {
+			s.fail(err)
+			return
+		}
+
+		c.handleWatcherEmissions(ctx, debouncer)
+	}()
+
+	go func() {
+		defer close(s.done)
+		defer c.devIsRunning.Store(false)
+
+		<-ctx.Done()
+
+		c.Logger.Info("Shutting down dev server")
+
+		// Stop processing file changes first (this waits for any in-flight
+		// batch to finish), so that nothing restarts the app behind our back
+		debouncer.stop()
+		c.watcher.Close()
+		watcherWG.Wait()
+
+		if err := c.killAppDev(); err != nil {
+			s.fail(err)
+		}
+		c.stopGoBinariesDev()
+		c.stopSidecarsDev()
+
+		if httpServer != nil {
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), devServerShutdownTimeout)
+			if err := httpServer.Shutdown(shutdownCtx); err != nil {
+				s.fail(fmt.Errorf("error: failed to shut down refresh server: %w", err))
+			}
+			shutdownCancel()
+		}
+
+		c.appEvents.closeAll()
+		close(managerStop)
+		<-c.manager.done
+	}()
+
+	return s, nil
+}
+
+func (c *Config) newRefreshServerMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		websocketHandler(c.manager)(w, r)
+	})
+
+	mux.HandleFunc(devAppEventsPath, c.appEvents.handler)
+
+	mux.HandleFunc("/get-refresh-script-inner", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "text/javascript")
+		w.Write([]byte(GetRefreshScriptInner(c.refreshServerPort)))
+	})
+
+	return mux
+}
+
+// cloneDevConfig copies the parts of a DevConfig that Kiruna rewrites
+// in place (watched file patterns), so that the caller's DevConfig can
+// be safely reused across StartDev calls.
+func cloneDevConfig(devConfig *DevConfig) *DevConfig {
+	clone := *devConfig
+	clone.WatchedFiles = make(WatchedFiles, len(devConfig.WatchedFiles))
+	for i, wfc := range devConfig.WatchedFiles {
+		clone.WatchedFiles[i] = wfc
+		clone.WatchedFiles[i].OnChangeCallbacks = make([]OnChange, len(wfc.OnChangeCallbacks))
+		for j, oc := range wfc.OnChangeCallbacks {
+			clone.WatchedFiles[i].OnChangeCallbacks[j] = oc
+			clone.WatchedFiles[i].OnChangeCallbacks[j].ExcludedPatterns = append([]string(nil), oc.ExcludedPatterns...)
+		}
+	}
+	clone.Sidecars = make([]DevSidecar, len(devConfig.Sidecars))
+	for i, sc := range devConfig.Sidecars {
+		clone.Sidecars[i] = sc
+		clone.Sidecars[i].Args = append([]string(nil), sc.Args...)
+		clone.Sidecars[i].Env = append([]string(nil), sc.Env...)
+	}
+	return &clone
+}
@@ -0,0 +1,162 @@
+package ik
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testAppMainGo = `package main
+
+import (
+	"net/http"
+	"os"
+)
+
+func main() {
+	http.ListenAndServe(":"+os.Getenv("PORT"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+}
+`
+
+func TestStartDevLifecycle(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+	defer resetEnv()
+
+	env.createTestFile(t, "cmd/app/main.go", testAppMainGo)
+	env.createTestFile(t, "critical.css", "body { color: red; }")
+	env.createTestFile(t, "main.css", "p { font-size: 16px; }")
+	env.config.MainAppEntry = filepath.Join(testRootDir, "cmd/app/main.go")
+
+	os.Setenv(portKey, "18080")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := env.config.StartDev(ctx, &DevConfig{WatchRoot: testRootDir, HealthcheckEndpoint: "/"})
+	if err != nil {
+		t.Fatalf("StartDev() error = %v", err)
+	}
+
+	if _, err := env.config.StartDev(ctx, &DevConfig{WatchRoot: testRootDir}); err == nil {
+		t.Errorf("second StartDev() error = nil, want already running error")
+	}
+
+	appURL := fmt.Sprintf("http://localhost:%d/", env.config.port)
+	refreshURL := fmt.Sprintf("http://localhost:%d/get-refresh-script-inner", env.config.refreshServerPort)
+
+	if GetIsDev() || getPortHasBeenSet() || getRefreshServerPort() != 0 {
+		t.Errorf("StartDev() leaked dev state into the process env")
+	}
+	if !env.config.getIsDev() {
+		t.Errorf("getIsDev() = false after StartDev(), want true")
+	}
+
+	if !env.config.waitForAppReadiness() {
+		t.Fatalf("app never became ready")
+	}
+	if resp, err := http.Get(refreshURL); err != nil {
+		t.Fatalf("refresh server not reachable: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+
+	cancel()
+
+	select {
+	case <-server.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("dev server did not shut down")
+	}
+
+	if err := server.Wait(); err != nil {
+		t.Errorf("Wait() error = %v, want nil", err)
+	}
+	if _, err := http.Get(appURL); err == nil {
+		t.Errorf("app still reachable after shutdown")
+	}
+	if _, err := http.Get(refreshURL); err == nil {
+		t.Errorf("refresh server still reachable after shutdown")
+	}
+}
+
+func TestStartDevFailedBuildClosesWatcher(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+	defer resetEnv()
+
+	env.createTestFile(t, "critical.css", "body { color: red; }")
+	env.createTestFile(t, "main.css", "p { font-size: 16px; }")
+	env.createTestFile(t, "client/main.ts", "import './does-not-exist';")
+	env.config.cleanSources.ScriptEntries = map[string]string{"main": filepath.Join(testRootDir, "client/main.ts")}
+
+	for attempt := range 2 {
+		if _, err := env.config.StartDev(context.Background(), &DevConfig{WatchRoot: testRootDir, HealthcheckEndpoint: "/"}); err == nil {
+			t.Fatalf("StartDev() attempt %d error = nil, want build error", attempt)
+		}
+		if err := env.config.watcher.Add(testRootDir); err == nil {
+			t.Errorf("watcher is still open after failed StartDev() attempt %d", attempt)
+		}
+	}
+}
+
+func TestAppDevIsReaped(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+	defer resetEnv()
+
+	binPath := env.config.getGoBinaryPath("", nil)
+	writeApp := func(script string) {
+		if err := os.MkdirAll(filepath.Dir(binPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(binPath, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	getDone := func() chan struct{} {
+		env.config.lastBuildCmd.mu.Lock()
+		defer env.config.lastBuildCmd.mu.Unlock()
+		return env.config.lastBuildDone
+	}
+
+	// An app that exits on its own is reaped without being killed
+	writeApp("printf partial; exit 1")
+	if err := env.config.startAppDev(); err != nil {
+		t.Fatalf("startAppDev() error = %v", err)
+	}
+	select {
+	case <-getDone():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("exited app was never reaped")
+	}
+	if err := env.config.killAppDev(); err != nil {
+		t.Errorf("killAppDev() after exit error = %v", err)
+	}
+
+	// A running app is only considered killed once it has been reaped
+	writeApp("exec sleep 60")
+	if err := env.config.startAppDev(); err != nil {
+		t.Fatalf("startAppDev() error = %v", err)
+	}
+	done := getDone()
+	if err := env.config.killAppDev(); err != nil {
+		t.Fatalf("killAppDev() error = %v", err)
+	}
+	select {
+	case <-done:
+	default:
+		t.Errorf("killAppDev() returned before the app was reaped")
+	}
+}
@@ -19,9 +19,10 @@ type DistBin struct {
 }
 
 type DistKiruna struct {
-	Static   *dirs.Dir[DistKirunaStatic]
-	Internal *dirs.Dir[DistKirunaInternal]
-	X        *dirs.File
+	Static             *dirs.Dir[DistKirunaStatic]
+	Internal           *dirs.Dir[DistKirunaInternal]
+	X                  *dirs.File
+	BuildReportDotJSON *dirs.File
 }
 
 type DistKirunaStatic struct {
@@ -37,12 +38,15 @@ type DistKirunaInternal struct {
 	CriticalDotCSS             *dirs.File
 	NormalCSSFileRefDotTXT     *dirs.File
 	PublicFileMapFileRefDotTXT *dirs.File
+	ScriptsFileRefDotJSON      *dirs.File
+	CSSPreloadsDotJSON         *dirs.File
+	GeneratedPublicDotJSON     *dirs.File
 }
 
 func toDistLayout(cleanDistDir string) *dirs.Dir[Dist] {
 	x := dirs.Build(cleanDistDir, dirs.ToRoot(Dist{
 		Bin: dirs.ToDir("bin", DistBin{
-			Main: dirs.ToFile("main"),
+			Main: dirs.ToFile(mainBinaryName),
 		}),
 		Kiruna: dirs.ToDir("kiruna", DistKiruna{
 			Static: dirs.ToDir("static", DistKirunaStatic{
@@ -55,8 +59,12 @@ func toDistLayout(cleanDistDir string) *dirs.Dir[Dist] {
 				CriticalDotCSS:             dirs.ToFile("critical.css"),
 				NormalCSSFileRefDotTXT:     dirs.ToFile("normal_css_file_ref.txt"),
 				PublicFileMapFileRefDotTXT: dirs.ToFile("public_file_map_file_ref.txt"),
+				ScriptsFileRefDotJSON:      dirs.ToFile("scripts_file_ref.json"),
+				CSSPreloadsDotJSON:         dirs.ToFile("css_preloads.json"),
+				GeneratedPublicDotJSON:     dirs.ToFile("generated_public.json"),
 			}),
-			X: dirs.ToFile("x"),
+			X:                  dirs.ToFile("x"),
+			BuildReportDotJSON: dirs.ToFile("build_report.json"),
 		}),
 	}))
 
@@ -1,7 +1,6 @@
 package ik
 
 import (
-	"fmt"
 	"os"
 	"strconv"
 
@@ -17,16 +16,15 @@ const (
 	trueStr              = "true"
 	isBuildTimeKey       = "KIRUNA_IS_BUILD_TIME"
 	useVerboseLogsKey    = "KIRUNA_USE_VERBOSE_LOGS"
+	useDevProxyKey       = "KIRUNA_USE_DEV_PROXY"
+	devProxyInjectsKey   = "KIRUNA_DEV_PROXY_INJECTS_REFRESH_SCRIPT"
+	appEventsURLKey      = "KIRUNA_APP_EVENTS_URL"
 )
 
 func GetIsDev() bool {
 	return os.Getenv(modeKey) == devModeVal
 }
 
-func setPort(port int) {
-	os.Setenv(portKey, fmt.Sprintf("%d", port))
-}
-
 func getPort() int {
 	port, err := strconv.Atoi(os.Getenv(portKey))
 	if err != nil {
@@ -35,10 +33,6 @@ func getPort() int {
 	return port
 }
 
-func setPortHasBeenSet() {
-	os.Setenv(portHasBeenSetKey, trueStr)
-}
-
 func getPortHasBeenSet() bool {
 	return os.Getenv(portHasBeenSetKey) == trueStr
 }
@@ -51,14 +45,80 @@ func getRefreshServerPort() int {
 	return port
 }
 
+// Deprecated: Use Kiruna.SetModeToDev instead. This puts every Kiruna
+// instance in the process that hasn't had its mode set into dev mode, as
+// they fall back to KIRUNA_MODE (see Config.getIsDev).
 func SetModeToDev() {
 	os.Setenv(modeKey, devModeVal)
 }
 
-func setRefreshServerPort(port int) {
-	os.Setenv(refreshServerPortKey, fmt.Sprintf("%d", port))
-}
-
 func getUseVerboseLogs() bool {
 	return envutil.GetBool(useVerboseLogsKey, false)
 }
+
+func getUseDevProxy() bool {
+	return os.Getenv(useDevProxyKey) == trueStr
+}
+
+func getDevProxyInjectsRefreshScript() bool {
+	return getUseDevProxy() && os.Getenv(devProxyInjectsKey) == trueStr
+}
+
+// The methods below resolve state that the dev server shares with the app
+// it runs. In the dev server process, it lives on the Config instance. In
+// the app process, it is read from the env vars that the dev server passes
+// down via getAppEnvDev.
+
+// Only instances whose mode was never set (i.e., those in the app process)
+// read KIRUNA_MODE.
+func (c *Config) getIsDev() bool {
+	if c.hasExplicitMode.Load() {
+		return c.isDevMode.Load()
+	}
+	return GetIsDev()
+}
+
+func (c *Config) setModeToDev() {
+	c.isDevMode.Store(true)
+	c.hasExplicitMode.Store(true)
+}
+
+// SetModeToDev puts this instance (and only this instance) into dev mode,
+// as StartDev does. Use it for dev work that runs before StartDev, like
+// build hooks and code generation.
+func (c *Config) SetModeToDev() {
+	c.setModeToDev()
+}
+
+func (c *Config) getRefreshServerPort() int {
+	if c.refreshServerPort != 0 {
+		return c.refreshServerPort
+	}
+	return getRefreshServerPort()
+}
+
+func (c *Config) getUseDevProxy() bool {
+	return c.useDevProxy || getUseDevProxy()
+}
+
+func (c *Config) getDevProxyInjectsRefreshScript() bool {
+	if c.useDevProxy {
+		return c.devProxyInjectsScript
+	}
+	return getDevProxyInjectsRefreshScript()
+}
+
+// getAppEnvDev returns the env for the app child process. Every shared key is
+// set explicitly, so that nothing leaks in from the dev server's own env.
+func (c *Config) getAppEnvDev() []string {
+	return append(
+		append(getPrefixedOutputEnv(), os.Environ()...),
+		modeKey+"="+devModeVal,
+		portKey+"="+strconv.Itoa(c.getAppPortDev()),
+		portHasBeenSetKey+"="+trueStr,
+		refreshServerPortKey+"="+strconv.Itoa(c.refreshServerPort),
+		useDevProxyKey+"="+strconv.FormatBool(c.useDevProxy),
+		devProxyInjectsKey+"="+strconv.FormatBool(c.devProxyInjectsScript),
+		appEventsURLKey+"="+c.getAppEventsURL(),
+	)
+}
@@ -2,6 +2,7 @@ package ik
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -32,17 +33,17 @@ func TestGetIsDev(t *testing.T) {
 func TestPortFunctions(t *testing.T) {
 	resetEnv()
 
-	// Test setPort and getPort
-	setPort(8080)
+	// Test getPort
+	os.Setenv(portKey, "8080")
 	if got := getPort(); got != 8080 {
 		t.Errorf("getPort() = %v, want %v", got, 8080)
 	}
 
-	// Test setPortHasBeenSet and getPortHasBeenSet
+	// Test getPortHasBeenSet
 	if getPortHasBeenSet() {
 		t.Errorf("getPortHasBeenSet() = true, want false before setting")
 	}
-	setPortHasBeenSet()
+	os.Setenv(portHasBeenSetKey, trueStr)
 	if !getPortHasBeenSet() {
 		t.Errorf("getPortHasBeenSet() = false, want true after setting")
 	}
@@ -51,8 +52,102 @@ func TestPortFunctions(t *testing.T) {
 func TestRefreshServerPort(t *testing.T) {
 	resetEnv()
 
-	setRefreshServerPort(3000)
+	os.Setenv(refreshServerPortKey, "3000")
 	if got := getRefreshServerPort(); got != 3000 {
 		t.Errorf("getRefreshServerPort() = %v, want %v", got, 3000)
 	}
+
+	// Instance state takes precedence over the env (app process fallback)
+	c := &Config{}
+	if got := c.getRefreshServerPort(); got != 3000 {
+		t.Errorf("Config.getRefreshServerPort() = %v, want %v", got, 3000)
+	}
+	c.refreshServerPort = 4000
+	if got := c.getRefreshServerPort(); got != 4000 {
+		t.Errorf("Config.getRefreshServerPort() = %v, want %v", got, 4000)
+	}
+}
+
+func TestModeIsPerInstance(t *testing.T) {
+	resetEnv()
+
+	a, b := &Config{}, &Config{}
+	a.setModeToDev()
+
+	if !a.getIsDev() {
+		t.Errorf("a.getIsDev() = false, want true")
+	}
+	if b.getIsDev() {
+		t.Errorf("b.getIsDev() = true, want false")
+	}
+	if GetIsDev() {
+		t.Errorf("GetIsDev() = true, want false (instance mode must not touch the env)")
+	}
+
+	// Only instances without a mode of their own (app processes) read the env
+	os.Setenv(modeKey, devModeVal)
+	defer resetEnv()
+	if !b.getIsDev() {
+		t.Errorf("b.getIsDev() = false with KIRUNA_MODE set, want true")
+	}
+	a.isDevMode.Store(false)
+	if a.getIsDev() {
+		t.Errorf("a.getIsDev() = true, want its own mode to win over KIRUNA_MODE")
+	}
+}
+
+func TestMustGetPortIsPerInstance(t *testing.T) {
+	resetEnv()
+	defer resetEnv()
+
+	os.Setenv(portKey, "18090")
+
+	c := &Config{}
+	c.setModeToDev()
+	port := c.MustGetPort()
+	if port < 18090 {
+		t.Errorf("MustGetPort() = %d, want a free port from 18090", port)
+	}
+	if got := c.MustGetPort(); got != port {
+		t.Errorf("second MustGetPort() = %d, want %d", got, port)
+	}
+	if os.Getenv(portKey) != "18090" || getPortHasBeenSet() {
+		t.Errorf("MustGetPort() wrote to the env")
+	}
+
+	// The dev server passes its pick down to the app
+	os.Setenv(portHasBeenSetKey, trueStr)
+	if got := (&Config{}).MustGetPort(); got != 18090 {
+		t.Errorf("MustGetPort() in app process = %d, want 18090", got)
+	}
+}
+
+func TestGetAppEnvDev(t *testing.T) {
+	resetEnv()
+	os.Setenv(useDevProxyKey, trueStr)
+
+	c := &Config{}
+	c.port = 8080
+	c.refreshServerPort = 10001
+
+	child := map[string]string{}
+	for _, kv := range c.getAppEnvDev() {
+		k, v, _ := strings.Cut(kv, "=")
+		child[k] = v // later entries win, as with exec.Cmd
+	}
+
+	want := map[string]string{
+		modeKey:              devModeVal,
+		portKey:              "8080",
+		portHasBeenSetKey:    trueStr,
+		refreshServerPortKey: "10001",
+		useDevProxyKey:       "false",
+	}
+	for k, v := range want {
+		if child[k] != v {
+			t.Errorf("child env %s = %q, want %q", k, child[k], v)
+		}
+	}
+
+	resetEnv()
 }
@@ -16,21 +16,37 @@ type EvtDetails struct {
 	isCriticalCSS       bool
 	isNormalCSS         bool
 	isKirunaCSS         bool
+	isKirunaScript      bool
+	isUnrelatedGo       bool     // Outside every binary's import graph (see getIsGoFileInGraph)
+	goBinaries          []string // Affected Config.GoBinaries names
 	wfc                 *WatchedFile
 	isNonEmptyCHMODOnly bool
 }
 
 func (c *Config) getEvtDetails(evt fsnotify.Event) *EvtDetails {
-	cssImportURLsMu.RLock()
-	_, isImportedCritical := criticalReliedUponFiles[evt.Name]
-	_, isImportedNormal := normalReliedUponFiles[evt.Name]
-	cssImportURLsMu.RUnlock()
+	c.cssImportURLsMu.RLock()
+	_, isImportedCritical := c.criticalReliedUponFiles[evt.Name]
+	_, isImportedNormal := c.normalReliedUponFiles[evt.Name]
+	c.cssImportURLsMu.RUnlock()
 
 	isCriticalCSS := evt.Name == c.cleanSources.CriticalCSSEntry || isImportedCritical
 	isNormalCSS := evt.Name == c.cleanSources.NormalCSSEntry || isImportedNormal
 
 	isKirunaCSS := isCriticalCSS || isNormalCSS
 
+	c.scriptReliedUponFilesMu.RLock()
+	_, isKirunaScript := c.scriptReliedUponFiles[evt.Name]
+	c.scriptReliedUponFilesMu.RUnlock()
+
+	if !isKirunaScript {
+		for _, entry := range c.cleanSources.ScriptEntries {
+			if evt.Name == entry {
+				isKirunaScript = true
+				break
+			}
+		}
+	}
+
 	var matchingWatchedFile *WatchedFile
 
 	for _, wfc := range c.devConfig.WatchedFiles {
@@ -51,14 +67,38 @@ func (c *Config) getEvtDetails(evt fsnotify.Event) *EvtDetails {
 		}
 	}
 
-	isGo := filepath.Ext(evt.Name) == ".go"
+	isGoModOrWork := getIsGoModOrWork(evt.Name)
+	isGo := filepath.Ext(evt.Name) == ".go" || isGoModOrWork
 	if isGo && matchingWatchedFile != nil && matchingWatchedFile.TreatAsNonGo {
 		isGo = false
 	}
 
-	isOther := !isGo && !isKirunaCSS
+	var goBinaries []string
+	if isGo {
+		if isGoModOrWork {
+			for _, b := range c.GoBinaries {
+				goBinaries = append(goBinaries, b.Name)
+			}
+		} else {
+			goBinaries = c.getAffectedGoBinaries(evt.Name)
+		}
+	}
+
+	// From here on, isGo means the main app binary is affected. Unrelated Go
+	// files are handled like any other file, so they are only processed if a
+	// watched file matches them.
+	if isGo && !isGoModOrWork && !c.getIsAppGoFile(evt.Name) {
+		isGo = false
+	}
+	isExplicitlyIgnored := c.getIsIgnored(evt.Name, c.ignoredFilePatterns)
+
+	// Files the user ignored on purpose are not worth mentioning
+	isUnrelatedGo := filepath.Ext(evt.Name) == ".go" && !isGo && len(goBinaries) == 0 && !isExplicitlyIgnored &&
+		(matchingWatchedFile == nil || !matchingWatchedFile.TreatAsNonGo)
+
+	isOther := !isGo && !isKirunaCSS && !isKirunaScript && len(goBinaries) == 0
 
-	isIgnored := c.getIsIgnored(evt.Name, c.ignoredFilePatterns)
+	isIgnored := isExplicitlyIgnored
 	if isOther && matchingWatchedFile == nil {
 		isIgnored = true
 	}
@@ -67,6 +107,9 @@ func (c *Config) getEvtDetails(evt fsnotify.Event) *EvtDetails {
 		evt:                 &evt,
 		isOther:             isOther,
 		isKirunaCSS:         isKirunaCSS,
+		isKirunaScript:      isKirunaScript,
+		isUnrelatedGo:       isUnrelatedGo,
+		goBinaries:          goBinaries,
 		isGo:                isGo,
 		isIgnored:           isIgnored,
 		isCriticalCSS:       isCriticalCSS,
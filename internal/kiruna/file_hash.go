@@ -2,6 +2,8 @@ package ik
 
 import (
 	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
 	"fmt"
 	"hash"
 	"io"
@@ -10,29 +12,48 @@ import (
 	"strings"
 )
 
-func getHashedFilenameFromPath(filePath string, originalFileName string) (string, error) {
+// getHashedFilenameFromPath also returns an SRI digest of the file, as
+// both are computed in the same pass.
+func getHashedFilenameFromPath(filePath string, originalFileName string) (string, string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	defer file.Close()
 
 	hash := sha256.New()
-	buf := make([]byte, 32*1024)
-	for {
-		n, err := file.Read(buf)
-		if n > 0 {
-			hash.Write(buf[:n])
-		}
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return "", err
-		}
+	sriHash := sha512.New384()
+	if _, err := io.Copy(io.MultiWriter(hash, sriHash), file); err != nil {
+		return "", "", err
+	}
+
+	return toOutputFileName(hash, originalFileName), toSRIDigest(sriHash), nil
+}
+
+func getSRIDigestFromPath(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
 	}
+	defer file.Close()
+
+	sriHash := sha512.New384()
+	if _, err := io.Copy(sriHash, file); err != nil {
+		return "", err
+	}
+
+	return toSRIDigest(sriHash), nil
+}
+
+// getSRIDigest returns a Subresource Integrity digest (e.g., "sha384-...")
+func getSRIDigest(content []byte) string {
+	sriHash := sha512.New384()
+	sriHash.Write(content)
+	return toSRIDigest(sriHash)
+}
 
-	return toOutputFileName(hash, originalFileName), nil
+func toSRIDigest(sriHash hash.Hash) string {
+	return "sha384-" + base64.StdEncoding.EncodeToString(sriHash.Sum(nil))
 }
 
 func getHashedFilenameFromBytes(content []byte, originalFileName string) string {
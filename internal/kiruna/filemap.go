@@ -7,6 +7,7 @@ import (
 	"html/template"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -62,6 +63,16 @@ func (c *Config) saveMapToGob(mapToSave FileMap, dest string) error {
 	return encoder.Encode(mapToSave)
 }
 
+func (c *Config) savePublicFileMap(mapToSave FileMap) error {
+	if err := c.saveMapToGob(mapToSave, PublicFileMapGobName); err != nil {
+		return fmt.Errorf("error saving file map: %v", err)
+	}
+	if err := c.savePublicFileMapJSToInternalPublicDir(mapToSave); err != nil {
+		return fmt.Errorf("error saving public file map JSON: %v", err)
+	}
+	return nil
+}
+
 func (c *Config) savePublicFileMapJSToInternalPublicDir(mapToSave FileMap) error {
 	simpleStrMap := make(map[string]string, len(mapToSave))
 	for k, v := range mapToSave {
@@ -82,6 +93,13 @@ func (c *Config) savePublicFileMapJSToInternalPublicDir(mapToSave FileMap) error
 		return fmt.Errorf("error writing to file: %v", err)
 	}
 
+	if err := c.recordGeneratedPublicFile(generatedPublicFileMap, path.Join(
+		c.__dist.S().Kiruna.S().Static.S().Public.S().PublicInternal.LastSegment(),
+		hashedFilename,
+	), bytes); err != nil {
+		return err
+	}
+
 	return os.WriteFile(filepath.Join(
 		c.__dist.S().Kiruna.S().Static.S().Public.S().PublicInternal.FullPath(),
 		hashedFilename,
@@ -91,6 +109,10 @@ func (c *Config) savePublicFileMapJSToInternalPublicDir(mapToSave FileMap) error
 type publicFileMapDetails struct {
 	Elements   template.HTML
 	Sha256Hash string
+
+	// Kept for rendering the nonce variant per request
+	linkAttrs       map[string]string
+	scriptInnerHTML template.HTML
 }
 
 func (c *Config) getInitialPublicFileMapDetails() (*publicFileMapDetails, error) {
@@ -99,21 +121,39 @@ func (c *Config) getInitialPublicFileMapDetails() (*publicFileMapDetails, error)
 		if (!window.kiruna) window.kiruna = {};
 		function getPublicURL(originalPublicURL) { 
 			if (originalPublicURL.startsWith("/")) originalPublicURL = originalPublicURL.slice(1);
-			return "/public/" + (kirunaPublicFileMap[originalPublicURL] || originalPublicURL);
+			return %s + (kirunaPublicFileMap[originalPublicURL] || originalPublicURL);
 		}
 		window.kiruna.getPublicURL = getPublicURL;` + "\n"
 
+	// Lets the refresh script update the map when public assets are swapped
+	if c.getIsDev() {
+		innerHTMLFormatStr += "\t\twindow.kiruna.publicFileMap = kirunaPublicFileMap;\n"
+	}
+
 	publicFileMapURL := c.GetPublicFileMapURL()
 
-	linkEl := htmlutil.Element{
-		Tag:        "link",
-		Attributes: map[string]string{"rel": "modulepreload", "href": publicFileMapURL},
+	publicURLBaseJSON, err := json.Marshal(c.getPublicURLBase())
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling public URL base: %v", err)
+	}
+
+	linkAttrs := map[string]string{"rel": "modulepreload", "href": publicFileMapURL}
+
+	// Without a readable ref or digest (e.g., no public static dir), the
+	// elements are still usable, just without SRI
+	if integrity, err := c.getPublicFileMapIntegrity(); err != nil {
+		c.Logger.Warn(fmt.Sprintf("omitting public file map integrity attribute: %v", err))
+	} else {
+		linkAttrs["integrity"] = integrity
+		linkAttrs["crossorigin"] = "anonymous"
 	}
 
+	linkEl := htmlutil.Element{Tag: "link", Attributes: linkAttrs}
+
 	scriptEl := htmlutil.Element{
 		Tag:        "script",
 		Attributes: map[string]string{"type": "module"},
-		InnerHTML:  template.HTML(fmt.Sprintf(innerHTMLFormatStr, publicFileMapURL)),
+		InnerHTML:  template.HTML(fmt.Sprintf(innerHTMLFormatStr, publicFileMapURL, publicURLBaseJSON)),
 	}
 
 	sha256Hash, err := htmlutil.AddSha256HashInline(&scriptEl, true)
@@ -133,17 +173,33 @@ func (c *Config) getInitialPublicFileMapDetails() (*publicFileMapDetails, error)
 	}
 
 	return &publicFileMapDetails{
-		Elements:   template.HTML(htmlBuilder.String()),
-		Sha256Hash: sha256Hash,
+		Elements:        template.HTML(htmlBuilder.String()),
+		Sha256Hash:      sha256Hash,
+		linkAttrs:       linkAttrs,
+		scriptInnerHTML: scriptEl.InnerHTML,
 	}, nil
 }
 
-func (c *Config) getInitialPublicFileMapURL() (string, error) {
-	baseFS, err := c.GetBaseFS()
+func (c *Config) getPublicFileMapIntegrity() (string, error) {
+	publicFileMapFileName, err := c.readPublicFileMapFileRef()
 	if err != nil {
-		c.Logger.Error(fmt.Sprintf("error getting FS: %v", err))
 		return "", err
 	}
+	integrity, err := c.getPublicDistFileIntegrity(path.Join(
+		c.__dist.S().Kiruna.S().Static.S().Public.S().PublicInternal.LastSegment(),
+		publicFileMapFileName,
+	))
+	if err != nil {
+		return "", fmt.Errorf("error getting public file map integrity: %v", err)
+	}
+	return integrity, nil
+}
+
+func (c *Config) readPublicFileMapFileRef() (string, error) {
+	baseFS, err := c.GetBaseFS()
+	if err != nil {
+		return "", fmt.Errorf("error getting FS: %v", err)
+	}
 
 	distKirunaInternal := c.__dist.S().Kiruna.S().Internal
 
@@ -154,14 +210,22 @@ func (c *Config) getInitialPublicFileMapURL() (string, error) {
 			distKirunaInternal.S().PublicFileMapFileRefDotTXT.LastSegment(),
 		))
 	if err != nil {
-		c.Logger.Error(fmt.Sprintf("error reading publicFileMapFileRefFile: %v", err))
+		return "", fmt.Errorf("error reading publicFileMapFileRefFile: %v", err)
+	}
+
+	return string(content), nil
+}
+
+func (c *Config) getInitialPublicFileMapURL() (string, error) {
+	fileName, err := c.readPublicFileMapFileRef()
+	if err != nil {
+		c.Logger.Error(err.Error())
 		return "", err
 	}
 
-	return "/" + filepath.Join(
-		PUBLIC,
+	return c.getPublicURLBase() + path.Join(
 		c.__dist.S().Kiruna.S().Static.S().Public.S().PublicInternal.LastSegment(),
-		string(content),
+		fileName,
 	), nil
 }
 
@@ -172,15 +236,64 @@ func (c *Config) GetPublicFileMapURL() string {
 func (c *Config) GetPublicFileMap() (FileMap, error) {
 	return c.runtimeCache.publicFileMapFromGob.Get()
 }
+func (c *Config) getPublicFileMapDetails() *publicFileMapDetails {
+	details, err := c.runtimeCache.publicFileMapDetails.Get()
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error getting public file map details: %v", err))
+		return nil
+	}
+	return details
+}
 func (c *Config) GetPublicFileMapElements() template.HTML {
-	details, _ := c.runtimeCache.publicFileMapDetails.Get()
+	details := c.getPublicFileMapDetails()
+	if details == nil {
+		return ""
+	}
 	return details.Elements
 }
 func (c *Config) GetPublicFileMapScriptSha256Hash() string {
-	details, _ := c.runtimeCache.publicFileMapDetails.Get()
+	details := c.getPublicFileMapDetails()
+	if details == nil {
+		return ""
+	}
 	return details.Sha256Hash
 }
 
+// GetPublicFileMapElementsWithNonce is like GetPublicFileMapElements, but
+// renders nonce attributes instead of relying on a CSP hash. If nonce is
+// empty, it returns the same elements as GetPublicFileMapElements.
+func (c *Config) GetPublicFileMapElementsWithNonce(nonce string) template.HTML {
+	if nonce == "" {
+		return c.GetPublicFileMapElements()
+	}
+	details := c.getPublicFileMapDetails()
+	if details == nil {
+		return ""
+	}
+
+	linkAttrs := make(map[string]string, len(details.linkAttrs)+1)
+	for k, v := range details.linkAttrs {
+		linkAttrs[k] = v
+	}
+	linkAttrs["nonce"] = nonce
+
+	var htmlBuilder strings.Builder
+	for _, el := range []*htmlutil.Element{
+		{Tag: "link", Attributes: linkAttrs},
+		{
+			Tag:        "script",
+			Attributes: map[string]string{"type": "module", "nonce": nonce},
+			InnerHTML:  details.scriptInnerHTML,
+		},
+	} {
+		if err := htmlutil.RenderElementToBuilder(el, &htmlBuilder); err != nil {
+			c.Logger.Error(fmt.Sprintf("error rendering element to builder: %v", err))
+			return ""
+		}
+	}
+	return template.HTML(htmlBuilder.String())
+}
+
 func (c *Config) GetPublicFileMapKeysBuildtime() ([]string, error) {
 	filemap, err := c.getInitialPublicFileMapFromGobBuildtime()
 	if err != nil {
@@ -0,0 +1,37 @@
+package ik
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPublicFileMapElementsWithoutPublicDir(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.config.PublicStaticDir = ""
+
+	elements := env.config.GetPublicFileMapElements()
+	if !strings.Contains(string(elements), `rel="modulepreload"`) {
+		t.Errorf("GetPublicFileMapElements() = %q, want a modulepreload link", elements)
+	}
+	link, _, _ := strings.Cut(string(elements), "<script")
+	if strings.Contains(link, "integrity") || strings.Contains(link, "crossorigin") {
+		t.Errorf("GetPublicFileMapElements() = %q, want no integrity attribute without a file map ref", elements)
+	}
+	if env.config.GetPublicFileMapScriptSha256Hash() == "" {
+		t.Errorf("GetPublicFileMapScriptSha256Hash() is empty")
+	}
+}
+
+func TestPublicFileMapElementsWithNonceWithoutPublicDir(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.config.PublicStaticDir = ""
+
+	elements := string(env.config.GetPublicFileMapElementsWithNonce("abc"))
+	if strings.Count(elements, `nonce="abc"`) != 2 {
+		t.Errorf("GetPublicFileMapElementsWithNonce() = %q, want a nonce on both elements", elements)
+	}
+}
@@ -0,0 +1,337 @@
+package ik
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sjc5/kit/pkg/grace"
+)
+
+// GoBinary is an additional Go program (e.g., a background worker or a
+// sidecar) that is built alongside MainAppEntry, and run by the dev loop.
+type GoBinary struct {
+	// Required. Used as the output name (dist/bin/<Name>) and as the prefix of
+	// the process's logs in dev. Must be unique, contain no slashes, and not
+	// collide with the main binary ("main").
+	Name string
+
+	// Required. Like MainAppEntry (e.g., "./cmd/worker").
+	Entry string
+
+	Args []string // Passed to the process in dev
+	Env  []string // Extra "KEY=VALUE" entries for the process in dev
+
+	// Optional. Polled after every (re)start in dev until it returns a 200
+	// (e.g., "http://localhost:9090/healthz"). Failures are only logged.
+	ReadinessURL string
+
+	// One of GoBinaryRestartOnChange (default), GoBinaryRestartAlways, or
+	// GoBinaryRestartNever.
+	RestartPolicy string
+}
+
+const (
+	// Restarted whenever a Go file in its import graph changes
+	GoBinaryRestartOnChange = "on-change"
+
+	// Like GoBinaryRestartOnChange, and also restarted if it exits on its own
+	GoBinaryRestartAlways = "always"
+
+	// Rebuilt on changes, but the running process is left alone until the dev
+	// server is restarted
+	GoBinaryRestartNever = "never"
+)
+
+const (
+	mainBinaryName       = "main" // See DistBin
+	goBinaryStopTimeout  = 5 * time.Second
+	goBinaryRestartDelay = time.Second
+)
+
+type goBinaryProcess struct {
+	cmd      *exec.Cmd
+	done     chan struct{} // Closed once the process has exited
+	stopping atomic.Bool
+	stdout   *prefixWriter
+	stderr   *prefixWriter
+}
+
+func (c *Config) validateGoBinaries() {
+	seen := map[string]bool{mainBinaryName: true}
+	for _, b := range c.GoBinaries {
+		if b.Name == "" || strings.ContainsAny(b.Name, `/\`) {
+			panic(fmt.Sprintf("invalid name (%q) in kiruna.Config.GoBinaries. Names must be non-empty and contain no slashes.", b.Name))
+		}
+		if seen[b.Name] {
+			panic(fmt.Sprintf("duplicate name (%q) in kiruna.Config.GoBinaries", b.Name))
+		}
+		seen[b.Name] = true
+		if b.Entry == "" {
+			panic(fmt.Sprintf("empty entry for Go binary %q in kiruna.Config.GoBinaries", b.Name))
+		}
+		switch b.RestartPolicy {
+		case "", GoBinaryRestartOnChange, GoBinaryRestartAlways, GoBinaryRestartNever:
+		default:
+			panic(fmt.Sprintf("invalid restart policy (%q) for Go binary %q in kiruna.Config.GoBinaries", b.RestartPolicy, b.Name))
+		}
+	}
+}
+
+func (c *Config) getGoBinary(name string) *GoBinary {
+	for i := range c.GoBinaries {
+		if c.GoBinaries[i].Name == name {
+			return &c.GoBinaries[i]
+		}
+	}
+	return nil
+}
+
+// startGoBinariesDev compiles and starts every Config.GoBinaries entry. Build
+// errors are logged rather than returned, just like for the main app.
+func (c *Config) startGoBinariesDev() {
+	for i := range c.GoBinaries {
+		b := &c.GoBinaries[i]
+		if err := c.compileGoBinary(b.Name, b.Entry, nil); err != nil {
+			c.Logger.Error(fmt.Sprintf("error: failed to build Go binary %s: %v", b.Name, err))
+			continue
+		}
+		c.restartGoBinaryDev(b)
+	}
+}
+
+// rebuildGoBinariesDev recompiles the named binaries, and restarts them
+// according to their restart policies.
+func (c *Config) rebuildGoBinariesDev(names []string) error {
+	for _, name := range names {
+		b := c.getGoBinary(name)
+		if b == nil {
+			continue
+		}
+		c.Logger.Info("Recompiling Go binary", "name", name)
+		if err := c.compileGoBinary(b.Name, b.Entry, nil); err != nil {
+			return fmt.Errorf("error compiling Go binary %s: %w", name, err)
+		}
+		if b.RestartPolicy == GoBinaryRestartNever && c.getIsGoBinaryRunningDev(name) {
+			c.Logger.Info("Not restarting Go binary (RestartPolicy is never)", "name", name)
+			continue
+		}
+		c.restartGoBinaryDev(b)
+	}
+	return nil
+}
+
+func (c *Config) restartGoBinaryDev(b *GoBinary) {
+	c.goBinaryProcesses.mu.Lock()
+	old := c.detachGoBinaryDevLocked(b.Name)
+	c.goBinaryProcesses.mu.Unlock()
+
+	c.terminateGoBinaryDev(b.Name, old)
+
+	c.goBinaryProcesses.mu.Lock()
+	err := c.startGoBinaryDevLocked(b)
+	c.goBinaryProcesses.mu.Unlock()
+
+	if err != nil {
+		c.Logger.Error(err.Error())
+		return
+	}
+
+	// Nothing waits on readiness, so don't hold up the dev loop for it
+	if b.ReadinessURL != "" {
+		go func() {
+			ctx := c.getDevCtx()
+			if !waitForReadiness(ctx, b.ReadinessURL) && ctx.Err() == nil {
+				c.Logger.Warn(fmt.Sprintf("Go binary %s never became ready (%s)", b.Name, b.ReadinessURL))
+			}
+		}()
+	}
+}
+
+func (c *Config) getIsGoBinaryRunningDev(name string) bool {
+	c.goBinaryProcesses.mu.Lock()
+	defer c.goBinaryProcesses.mu.Unlock()
+	p, ok := c.goBinaryProcesses.v[name]
+	if !ok {
+		return false
+	}
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *Config) startGoBinaryDevLocked(b *GoBinary) error {
+	prefix := "[" + b.Name + "] "
+	p := &goBinaryProcess{
+		cmd:    exec.Command(c.getGoBinaryPath(b.Name, nil), b.Args...),
+		done:   make(chan struct{}),
+		stdout: newPrefixWriter(os.Stdout, prefix),
+		stderr: newPrefixWriter(os.Stderr, prefix),
+	}
+	p.cmd.Stdout = p.stdout
+	p.cmd.Stderr = p.stderr
+	p.cmd.Env = append(append(append(getPrefixedOutputEnv(), os.Environ()...), modeKey+"="+devModeVal), b.Env...)
+
+	if err := p.cmd.Start(); err != nil {
+		return fmt.Errorf("error: failed to start Go binary %s: %v", b.Name, err)
+	}
+
+	if c.goBinaryProcesses.v == nil {
+		c.goBinaryProcesses.v = map[string]*goBinaryProcess{}
+	}
+	c.goBinaryProcesses.v[b.Name] = p
+	c.Logger.Info("Go binary is running", "name", b.Name, "pid", p.cmd.Process.Pid)
+
+	go c.waitForGoBinaryExit(b, p)
+	return nil
+}
+
+func (c *Config) waitForGoBinaryExit(b *GoBinary, p *goBinaryProcess) {
+	err := p.cmd.Wait()
+	p.stdout.flush()
+	p.stderr.flush()
+	close(p.done)
+
+	if p.stopping.Load() {
+		return
+	}
+	c.Logger.Warn(fmt.Sprintf("Go binary %s exited unexpectedly: %v", b.Name, err))
+	if b.RestartPolicy != GoBinaryRestartAlways {
+		return
+	}
+
+	time.Sleep(goBinaryRestartDelay)
+
+	c.goBinaryProcesses.mu.Lock()
+	defer c.goBinaryProcesses.mu.Unlock()
+	// Skip if it was stopped or replaced in the meantime
+	if p.stopping.Load() || c.goBinaryProcesses.v[b.Name] != p {
+		return
+	}
+	c.Logger.Info("Restarting Go binary", "name", b.Name)
+	if err := c.startGoBinaryDevLocked(b); err != nil {
+		c.Logger.Error(err.Error())
+	}
+}
+
+// detachGoBinaryDevLocked removes the named process from the running set,
+// so that it is no longer auto-restarted, and returns it (or nil).
+func (c *Config) detachGoBinaryDevLocked(name string) *goBinaryProcess {
+	p, ok := c.goBinaryProcesses.v[name]
+	if !ok {
+		return nil
+	}
+	p.stopping.Store(true)
+	delete(c.goBinaryProcesses.v, name)
+	return p
+}
+
+// terminateGoBinaryDev stops a detached process the same way the app is
+// stopped (see killAppDev). Must not be called with goBinaryProcesses.mu held.
+func (c *Config) terminateGoBinaryDev(name string, p *goBinaryProcess) {
+	if p == nil {
+		return
+	}
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+
+	// The process is reaped by waitForGoBinaryExit, so any error here is
+	// only worth reporting if it's still running
+	err := grace.TerminateProcess(p.cmd.Process, goBinaryStopTimeout, c.Logger)
+	select {
+	case <-p.done:
+		c.Logger.Info("Terminated Go binary", "name", name, "pid", p.cmd.Process.Pid)
+	case <-time.After(goBinaryStopTimeout):
+		c.Logger.Error(fmt.Sprintf("error: failed to terminate Go binary %s: %v", name, err))
+		p.cmd.Process.Kill()
+		<-p.done
+	}
+}
+
+func (c *Config) stopGoBinariesDev() {
+	c.goBinaryProcesses.mu.Lock()
+	procs := make(map[string]*goBinaryProcess, len(c.goBinaryProcesses.v))
+	for name := range c.goBinaryProcesses.v {
+		procs[name] = c.detachGoBinaryDevLocked(name)
+	}
+	c.goBinaryProcesses.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for name, p := range procs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.terminateGoBinaryDev(name, p)
+		}()
+	}
+	wg.Wait()
+}
+
+// getPrefixedOutputEnv returns the env entries that keep color on in processes
+// whose output goes through a prefixWriter, as their stdout and stderr are pipes
+// rather than the terminal. Place them before the inherited env, so that the
+// user's own settings win.
+func getPrefixedOutputEnv() []string {
+	if os.Getenv("NO_COLOR") != "" || !isTerminal(os.Stdout) {
+		return nil
+	}
+	return []string{"FORCE_COLOR=1", "CLICOLOR_FORCE=1"}
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// prefixWriter prefixes every line written to it, so that the output of
+// several processes can share a terminal. Partial lines are held until they
+// are completed (or flushed).
+type prefixWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func newPrefixWriter(w io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{w: w, prefix: []byte(prefix)}
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.buf = append(pw.buf, p...)
+	for {
+		i := bytes.IndexByte(pw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := pw.w.Write(append(append([]byte{}, pw.prefix...), pw.buf[:i+1]...)); err != nil {
+			return 0, err
+		}
+		pw.buf = pw.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (pw *prefixWriter) flush() {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if len(pw.buf) == 0 {
+		return
+	}
+	pw.w.Write(append(append(append([]byte{}, pw.prefix...), pw.buf...), '\n'))
+	pw.buf = nil
+}
@@ -0,0 +1,111 @@
+package ik
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	pw := newPrefixWriter(&out, "[worker] ")
+	pw.Write([]byte("one\ntw"))
+	pw.Write([]byte("o\nthree"))
+	if got, want := out.String(), "[worker] one\n[worker] two\n"; got != want {
+		t.Errorf("output before flush = %q, want %q", got, want)
+	}
+	pw.flush()
+	if got, want := out.String(), "[worker] one\n[worker] two\n[worker] three\n"; got != want {
+		t.Errorf("output after flush = %q, want %q", got, want)
+	}
+}
+
+func TestGoBinariesDev(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+	defer resetEnv()
+
+	env.createTestFile(t, "goapp/cmd/app/main.go", "package main\n\nfunc main() {}\n")
+	env.createTestFile(t, "goapp/cmd/worker/main.go", `package main
+
+import (
+	"time"
+
+	"github.com/sjc5/kiruna/internal/kiruna/testdata/goapp/lib"
+)
+
+func main() {
+	lib.Hello()
+	time.Sleep(time.Hour)
+}
+`)
+	env.createTestFile(t, "goapp/lib/lib.go", "package lib\n\nfunc Hello() { println(\"hello\") }\n")
+
+	env.config.MainAppEntry = "./" + filepath.Join(testRootDir, "goapp/cmd/app")
+	env.config.GoBinaries = []GoBinary{{Name: "worker", Entry: "./" + filepath.Join(testRootDir, "goapp/cmd/worker")}}
+	env.config.setModeToDev()
+	env.config.invalidateGoDepGraph()
+
+	libFile := filepath.Join(testRootDir, "goapp/lib/lib.go")
+	if env.config.getIsAppGoFile(libFile) {
+		t.Errorf("getIsAppGoFile(%q) = true, want false", libFile)
+	}
+	if got := env.config.getAffectedGoBinaries(libFile); len(got) != 1 || got[0] != "worker" {
+		t.Errorf("getAffectedGoBinaries(%q) = %v, want [worker]", libFile, got)
+	}
+
+	if err := env.config.SetupDistDir(); err != nil {
+		t.Fatalf("SetupDistDir() error = %v", err)
+	}
+	env.config.startGoBinariesDev()
+	defer env.config.stopGoBinariesDev()
+
+	getPid := func() int {
+		env.config.goBinaryProcesses.mu.Lock()
+		defer env.config.goBinaryProcesses.mu.Unlock()
+		p, ok := env.config.goBinaryProcesses.v["worker"]
+		if !ok {
+			return 0
+		}
+		return p.cmd.Process.Pid
+	}
+
+	firstPid := getPid()
+	if firstPid == 0 || !env.config.getIsGoBinaryRunningDev("worker") {
+		t.Fatalf("worker is not running after startGoBinariesDev()")
+	}
+
+	if err := env.config.rebuildGoBinariesDev([]string{"worker"}); err != nil {
+		t.Fatalf("rebuildGoBinariesDev() error = %v", err)
+	}
+	if pid := getPid(); pid == 0 || pid == firstPid {
+		t.Errorf("worker pid after rebuild = %d, want a new process (was %d)", pid, firstPid)
+	}
+
+	// An unresponsive ReadinessURL must not hold up rebuilds
+	env.config.GoBinaries[0].ReadinessURL = "http://127.0.0.1:1/healthz"
+	start := time.Now()
+	if err := env.config.rebuildGoBinariesDev([]string{"worker"}); err != nil {
+		t.Fatalf("rebuildGoBinariesDev() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("rebuildGoBinariesDev() took %v with an unresponsive ReadinessURL", elapsed)
+	}
+	env.config.GoBinaries[0].ReadinessURL = ""
+
+	// RestartPolicy never leaves the running process alone
+	env.config.GoBinaries[0].RestartPolicy = GoBinaryRestartNever
+	secondPid := getPid()
+	if err := env.config.rebuildGoBinariesDev([]string{"worker"}); err != nil {
+		t.Fatalf("rebuildGoBinariesDev() error = %v", err)
+	}
+	if pid := getPid(); pid != secondPid {
+		t.Errorf("worker pid after rebuild with RestartPolicy never = %d, want %d", pid, secondPid)
+	}
+
+	env.config.stopGoBinariesDev()
+	if env.config.getIsGoBinaryRunningDev("worker") {
+		t.Errorf("worker is still running after stopGoBinariesDev()")
+	}
+}
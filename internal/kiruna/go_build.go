@@ -0,0 +1,196 @@
+package ik
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type GoBuildOptions struct {
+	Dev  GoBuildSettings // Used when compiling in dev (by StartDev, or Build in dev mode)
+	Prod GoBuildSettings // Used for every other Build
+
+	// Prod only. If empty, each binary is built for the host (e.g., to dist/bin/main).
+	// Otherwise, each binary is built once per target (e.g., to dist/bin/main_<GOOS>_<GOARCH>,
+	// with an ".exe" suffix for windows), and the host binaries are not written.
+	Targets []GoBuildTarget
+}
+
+type GoBuildSettings struct {
+	Tags     []string // Passed as -tags
+	TrimPath bool     // Passes -trimpath
+	Race     bool     // Passes -race (requires cgo)
+
+	// Passed as -ldflags, along with LinkerVars (e.g., "-s -w")
+	LDFlags string
+
+	// Each is passed as "-X key=value" in -ldflags, e.g., for version stamping:
+	// {"main.version": "1.2.3"}
+	LinkerVars map[string]string
+
+	// If set, CGO_ENABLED is set to "1" or "0" accordingly. If nil, it is inherited.
+	CGOEnabled *bool
+
+	// Extra "KEY=VALUE" entries, added on top of the inherited environment
+	Env []string
+
+	// Any other flags to pass to go build, before the entry (e.g., {"-buildvcs=false"})
+	Flags []string
+}
+
+type GoBuildTarget struct {
+	GOOS   string
+	GOARCH string
+}
+
+// BuildGoReport records how the Go binaries were compiled. Env values are
+// left out, as they may contain secrets.
+type BuildGoReport struct {
+	Tags       []string        `json:"tags,omitempty"`
+	LDFlags    string          `json:"ldflags,omitempty"`
+	TrimPath   bool            `json:"trimpath,omitempty"`
+	Race       bool            `json:"race,omitempty"`
+	CGOEnabled *bool           `json:"cgoEnabled,omitempty"`
+	EnvKeys    []string        `json:"envKeys,omitempty"`
+	Flags      []string        `json:"flags,omitempty"`
+	Binaries   []BuildGoBinary `json:"binaries"`
+}
+
+type BuildGoBinary struct {
+	Name   string `json:"name"` // "main" for MainAppEntry, or the Config.GoBinaries name
+	Path   string `json:"path"` // Relative to dist/bin
+	GOOS   string `json:"goos,omitempty"`
+	GOARCH string `json:"goarch,omitempty"`
+	Size   int64  `json:"size"`
+}
+
+func (c *Config) getGoBuildSettings() *GoBuildSettings {
+	if c.getIsDev() {
+		return &c.GoBuild.Dev
+	}
+	return &c.GoBuild.Prod
+}
+
+// getGoBuildTargets returns nil if only the host binary should be built.
+func (c *Config) getGoBuildTargets() []GoBuildTarget {
+	if c.getIsDev() {
+		return nil
+	}
+	return c.GoBuild.Targets
+}
+
+// getGoBinaryName returns the output name for a Config.GoBinaries name, or
+// for the main app if binaryName is empty.
+func (c *Config) getGoBinaryName(binaryName string) string {
+	if binaryName == "" {
+		return c.__dist.S().Bin.S().Main.LastSegment()
+	}
+	return binaryName
+}
+
+func (c *Config) getGoBinaryPath(binaryName string, target *GoBuildTarget) string {
+	name := c.getGoBinaryName(binaryName)
+	if target != nil {
+		name = fmt.Sprintf("%s_%s_%s", name, target.GOOS, target.GOARCH)
+		if target.GOOS == "windows" {
+			name += ".exe"
+		}
+	}
+	return filepath.Join(c.__dist.S().Bin.FullPath(), name)
+}
+
+func (s *GoBuildSettings) getLDFlags() string {
+	parts := []string{}
+	if s.LDFlags != "" {
+		parts = append(parts, s.LDFlags)
+	}
+	keys := make([]string, 0, len(s.LinkerVars))
+	for key := range s.LinkerVars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("-X '%s=%s'", key, s.LinkerVars[key]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// getTagsArgs returns the -tags flag and value, or nil if there are no tags.
+// Shared with go list, so that both see the same files.
+func (s *GoBuildSettings) getTagsArgs() []string {
+	if len(s.Tags) == 0 {
+		return nil
+	}
+	return []string{"-tags", strings.Join(s.Tags, ",")}
+}
+
+func (s *GoBuildSettings) getBuildArgs(output, entry string) []string {
+	args := []string{"build", "-o", output}
+	args = append(args, s.getTagsArgs()...)
+	if ldflags := s.getLDFlags(); ldflags != "" {
+		args = append(args, "-ldflags", ldflags)
+	}
+	if s.TrimPath {
+		args = append(args, "-trimpath")
+	}
+	if s.Race {
+		args = append(args, "-race")
+	}
+	args = append(args, s.Flags...)
+	return append(args, entry)
+}
+
+// getEnv relies on later entries winning over earlier ones (see exec.Cmd.Env).
+func (s *GoBuildSettings) getEnv(target *GoBuildTarget) []string {
+	env := append(os.Environ(), s.Env...)
+	if s.CGOEnabled != nil {
+		cgo := "0"
+		if *s.CGOEnabled {
+			cgo = "1"
+		}
+		env = append(env, "CGO_ENABLED="+cgo)
+	}
+	if target != nil {
+		env = append(env, "GOOS="+target.GOOS, "GOARCH="+target.GOARCH)
+	}
+	return env
+}
+
+func (s *GoBuildSettings) toReport() *BuildGoReport {
+	report := &BuildGoReport{
+		Tags:       s.Tags,
+		LDFlags:    s.getLDFlags(),
+		TrimPath:   s.TrimPath,
+		Race:       s.Race,
+		CGOEnabled: s.CGOEnabled,
+		Flags:      s.Flags,
+		Binaries:   []BuildGoBinary{},
+	}
+	for _, kv := range s.Env {
+		key, _, _ := strings.Cut(kv, "=")
+		report.EnvKeys = append(report.EnvKeys, key)
+	}
+	return report
+}
+
+func (c *Config) validateGoBuildOptions() {
+	seen := make(map[GoBuildTarget]bool, len(c.GoBuild.Targets))
+	for _, target := range c.GoBuild.Targets {
+		if target.GOOS == "" || target.GOARCH == "" || strings.ContainsAny(target.GOOS+target.GOARCH, `/\`) {
+			panic(fmt.Sprintf("invalid target (%q/%q) in kiruna.Config.GoBuild.Targets", target.GOOS, target.GOARCH))
+		}
+		if seen[target] {
+			panic(fmt.Sprintf("duplicate target (%s/%s) in kiruna.Config.GoBuild.Targets", target.GOOS, target.GOARCH))
+		}
+		seen[target] = true
+	}
+	for _, settings := range []GoBuildSettings{c.GoBuild.Dev, c.GoBuild.Prod} {
+		for _, kv := range settings.Env {
+			if !strings.Contains(kv, "=") {
+				panic(fmt.Sprintf("invalid env entry (%q) in kiruna.Config.GoBuild. Must be in KEY=VALUE form.", kv))
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package ik
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	goruntime "runtime"
+	"strings"
+	"testing"
+)
+
+func TestGoBuildSettingsArgs(t *testing.T) {
+	cgo := false
+	settings := GoBuildSettings{
+		Tags:       []string{"netgo", "prod"},
+		TrimPath:   true,
+		LDFlags:    "-s -w",
+		LinkerVars: map[string]string{"main.version": "1.2.3", "main.commit": "abc"},
+		CGOEnabled: &cgo,
+		Env:        []string{"GOFLAGS=-mod=mod"},
+		Flags:      []string{"-buildvcs=false"},
+	}
+
+	want := []string{
+		"build", "-o", "out", "-tags", "netgo,prod",
+		"-ldflags", "-s -w -X 'main.commit=abc' -X 'main.version=1.2.3'",
+		"-trimpath", "-buildvcs=false", "./cmd/app",
+	}
+	if got := settings.getBuildArgs("out", "./cmd/app"); !reflect.DeepEqual(got, want) {
+		t.Errorf("getBuildArgs() = %v, want %v", got, want)
+	}
+
+	env := settings.getEnv(&GoBuildTarget{GOOS: "linux", GOARCH: "arm64"})
+	wantTail := []string{"GOFLAGS=-mod=mod", "CGO_ENABLED=0", "GOOS=linux", "GOARCH=arm64"}
+	if got := env[len(env)-len(wantTail):]; !reflect.DeepEqual(got, wantTail) {
+		t.Errorf("getEnv() ends with %v, want %v", got, wantTail)
+	}
+
+	report := settings.toReport()
+	if !reflect.DeepEqual(report.EnvKeys, []string{"GOFLAGS"}) || report.LDFlags != want[6] {
+		t.Errorf("toReport() = %+v, want env keys only and the full ldflags", report)
+	}
+}
+
+func TestGoBuildTargets(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "cmd/app/main.go", "package main\n\nvar version = \"dev\"\n\nfunc main() { println(version) }\n")
+	env.createTestFile(t, "critical.css", "p { margin: 0; }")
+	env.createTestFile(t, "main.css", "body { margin: 0; }")
+	env.config.MainAppEntry = filepath.Join(testRootDir, "cmd/app/main.go")
+
+	// The host platform keeps the test fast, while still going through the target path
+	target := GoBuildTarget{GOOS: goruntime.GOOS, GOARCH: goruntime.GOARCH}
+	env.config.GoBuild = GoBuildOptions{
+		Prod:    GoBuildSettings{TrimPath: true, LinkerVars: map[string]string{"main.version": "1.2.3"}},
+		Targets: []GoBuildTarget{target},
+	}
+
+	result, err := env.config.Build(true, false)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	binPath := env.config.getGoBinaryPath("", &target)
+	if !strings.HasSuffix(filepath.Base(strings.TrimSuffix(binPath, ".exe")), "main_"+target.GOOS+"_"+target.GOARCH) {
+		t.Errorf("getGoBinaryPath() = %q, want main_<os>_<arch>", binPath)
+	}
+	if _, err := os.Stat(binPath); err != nil {
+		t.Errorf("target binary was not written: %v", err)
+	}
+	if _, err := os.Stat(env.config.getGoBinaryPath("", nil)); err == nil {
+		t.Errorf("host binary was written even though targets are set")
+	}
+
+	report := result.GoBuild
+	if report == nil || len(report.Binaries) != 1 || !report.TrimPath || report.Binaries[0].GOOS != target.GOOS || report.Binaries[0].Size == 0 {
+		t.Errorf("BuildResult.GoBuild = %+v, want the trimpath target build", report)
+	}
+}
@@ -0,0 +1,151 @@
+package ik
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// goDepGraph records which Go files are compiled into a binary, per
+// "go list -deps" on its entry, so that the dev loop can skip recompiling
+// for changes that can't affect it.
+type goDepGraph struct {
+	files        map[string]struct{} // Absolute paths of compiled Go files
+	ignoredFiles map[string]struct{} // Excluded by inactive build constraints
+	dirs         map[string]struct{} // Package dirs, so that new files count too
+}
+
+type goListPackage struct {
+	ImportPath     string
+	Dir            string
+	Standard       bool
+	GoFiles        []string
+	CgoFiles       []string
+	IgnoredGoFiles []string
+}
+
+// Go's name for a package made up of files passed on the command line
+const goCommandLineArgumentsPkg = "command-line-arguments"
+
+func (c *Config) loadGoDepGraph(entry string) (*goDepGraph, error) {
+	settings := c.getGoBuildSettings()
+	args := append([]string{"list", "-deps", "-json=ImportPath,Dir,Standard,GoFiles,CgoFiles,IgnoredGoFiles"}, settings.getTagsArgs()...)
+	cmd := exec.Command("go", append(args, entry)...)
+	cmd.Env = settings.getEnv(nil)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error running go list: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	graph := &goDepGraph{
+		files:        map[string]struct{}{},
+		ignoredFiles: map[string]struct{}{},
+		dirs:         map[string]struct{}{},
+	}
+
+	dec := json.NewDecoder(&stdout)
+	for {
+		var pkg goListPackage
+		if err := dec.Decode(&pkg); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("error decoding go list output: %v", err)
+		}
+		if pkg.Standard || pkg.Dir == "" {
+			continue
+		}
+		// Only the listed files make up the package in this case, not its whole dir
+		if pkg.ImportPath != goCommandLineArgumentsPkg {
+			graph.dirs[filepath.Clean(pkg.Dir)] = struct{}{}
+		}
+		for _, files := range [][]string{pkg.GoFiles, pkg.CgoFiles} {
+			for _, file := range files {
+				graph.files[filepath.Join(pkg.Dir, file)] = struct{}{}
+			}
+		}
+		for _, file := range pkg.IgnoredGoFiles {
+			graph.ignoredFiles[filepath.Join(pkg.Dir, file)] = struct{}{}
+		}
+	}
+
+	return graph, nil
+}
+
+// getIsAppGoFile reports whether a change to the Go file at filePath could
+// affect the app binary. Errs on the side of true if the graph can't be loaded.
+func (c *Config) getIsAppGoFile(filePath string) bool {
+	return c.getIsGoFileInGraph(c.MainAppEntry, filePath)
+}
+
+// getAffectedGoBinaries returns the names of the Config.GoBinaries that a
+// change to the Go file at filePath could affect.
+func (c *Config) getAffectedGoBinaries(filePath string) []string {
+	var names []string
+	for _, b := range c.GoBinaries {
+		if c.getIsGoFileInGraph(b.Entry, filePath) {
+			names = append(names, b.Name)
+		}
+	}
+	return names
+}
+
+func (c *Config) getIsGoFileInGraph(entry, filePath string) bool {
+	c.goDeps.mu.Lock()
+	defer c.goDeps.mu.Unlock()
+
+	if c.goDeps.v == nil {
+		c.goDeps.v = map[string]*goDepGraph{}
+	}
+
+	graph, ok := c.goDeps.v[entry]
+	if !ok {
+		var err error
+		graph, err = c.loadGoDepGraph(entry)
+		if err != nil {
+			c.Logger.Error(fmt.Sprintf("error loading Go import graph of %s, treating every Go file as part of it: %v", entry, err))
+			return true
+		}
+		c.goDeps.v[entry] = graph
+	}
+
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return true
+	}
+	return graph.getIsInGraph(absPath)
+}
+
+func (g *goDepGraph) getIsInGraph(absPath string) bool {
+	if strings.HasSuffix(absPath, "_test.go") {
+		return false
+	}
+	if _, ok := g.files[absPath]; ok {
+		return true
+	}
+	if _, ok := g.ignoredFiles[absPath]; ok {
+		return false
+	}
+	_, ok := g.dirs[filepath.Dir(absPath)]
+	return ok
+}
+
+// invalidateGoDepGraph makes the next Go file change reload the graphs. Called
+// after every recompile, as imports (or go.mod / go.work) may have changed.
+func (c *Config) invalidateGoDepGraph() {
+	c.goDeps.mu.Lock()
+	c.goDeps.v = nil
+	c.goDeps.mu.Unlock()
+}
+
+func getIsGoModOrWork(filePath string) bool {
+	base := filepath.Base(filePath)
+	return base == "go.mod" || base == "go.work"
+}
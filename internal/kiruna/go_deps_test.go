@@ -0,0 +1,71 @@
+package ik
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestGoDepGraph(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "goapp/cmd/app/main.go", `package main
+
+import "github.com/sjc5/kiruna/internal/kiruna/testdata/goapp/lib"
+
+func main() { lib.Hello() }
+`)
+	env.createTestFile(t, "goapp/cmd/app/main_test.go", "package main\n")
+	env.createTestFile(t, "goapp/cmd/app/windows_only.go", "//go:build ignore\n\npackage main\n")
+	env.createTestFile(t, "goapp/lib/lib.go", "package lib\n\nfunc Hello() {}\n")
+	env.createTestFile(t, "goapp/cmd/other/main.go", "package main\n\nfunc main() {}\n")
+	env.createTestFile(t, "goapp/unused/unused.go", "package unused\n")
+
+	env.config.MainAppEntry = "./" + filepath.Join(testRootDir, "goapp/cmd/app")
+	env.config.invalidateGoDepGraph()
+
+	for file, want := range map[string]bool{
+		"goapp/cmd/app/main.go":         true,
+		"goapp/lib/lib.go":              true,
+		"goapp/lib/new_file.go":         true, // Not created yet, but in an app package
+		"goapp/cmd/app/main_test.go":    false,
+		"goapp/cmd/app/windows_only.go": false,
+		"goapp/cmd/other/main.go":       false,
+		"goapp/unused/unused.go":        false,
+	} {
+		if got := env.config.getIsAppGoFile(filepath.Join(testRootDir, file)); got != want {
+			t.Errorf("getIsAppGoFile(%q) = %v, want %v", file, got, want)
+		}
+	}
+
+	// Only Go files that are ignored because nothing watches them count as
+	// unrelated, not those the user ignored on purpose
+	env.config.devConfig = &DevConfig{IgnorePatterns: IgnorePatterns{Files: []string{"testdata/goapp/unused/*.go"}}}
+	env.config.cleanWatchRoot = "."
+	if err := env.config.devInit(); err != nil {
+		t.Fatalf("devInit() error = %v", err)
+	}
+	defer env.config.watcher.Close()
+	for file, want := range map[string]bool{
+		"goapp/cmd/other/main.go": true,
+		"goapp/unused/unused.go":  false,
+	} {
+		details := env.config.getEvtDetails(fsnotify.Event{Name: filepath.Join(testRootDir, file), Op: fsnotify.Write})
+		if !details.isIgnored || details.isUnrelatedGo != want {
+			t.Errorf("getEvtDetails(%q) isIgnored = %v, isUnrelatedGo = %v, want true and %v", file, details.isIgnored, details.isUnrelatedGo, want)
+		}
+	}
+
+	// Falls back to treating everything as part of the app
+	env.config.MainAppEntry = "./" + filepath.Join(testRootDir, "goapp/does-not-exist")
+	env.config.invalidateGoDepGraph()
+	if !env.config.getIsAppGoFile(filepath.Join(testRootDir, "goapp/cmd/other/main.go")) {
+		t.Errorf("getIsAppGoFile() = false with a broken MainAppEntry, want true")
+	}
+
+	if !getIsGoModOrWork("go.mod") || !getIsGoModOrWork("sub/go.work") || getIsGoModOrWork("go.sum") {
+		t.Errorf("getIsGoModOrWork() misclassified go.mod, go.work or go.sum")
+	}
+}
@@ -0,0 +1,77 @@
+package ik
+
+import (
+	"fmt"
+	"sort"
+)
+
+// In dev, import.meta.hot in bundled scripts resolves to a per-bundle context
+// created by the refresh script (see __kirunaCreateHot in refreshScriptFmt),
+// keyed by the bundle's own URL. In prod, it is undefined, so code guarded by
+// "if (import.meta.hot)" is dropped.
+
+const hmrContextIdentifier = "__kirunaHot"
+
+var hmrBannerJS = fmt.Sprintf(
+	"const %s = globalThis.__kirunaCreateHot ? globalThis.__kirunaCreateHot(import.meta.url) : undefined;",
+	hmrContextIdentifier,
+)
+
+func getHMRDefine(isDev bool) map[string]string {
+	if isDev {
+		return map[string]string{"import.meta.hot": hmrContextIdentifier}
+	}
+	return map[string]string{"import.meta.hot": "undefined"}
+}
+
+func getHMRBanner(isDev bool) map[string]string {
+	if isDev {
+		return map[string]string{"js": hmrBannerJS}
+	}
+	return nil
+}
+
+type hmrUpdate struct {
+	Name       string `json:"name"`
+	OldURL     string `json:"oldURL"`
+	NewURL     string `json:"newURL"`
+	HashedName string `json:"hashedName"` // New value for the browser's public file map
+}
+
+// getHMRUpdates compares script outputs from before and after a rebuild, and
+// returns an update for every bundle whose content changed.
+func (c *Config) getHMRUpdates(oldOutputs, newOutputs map[string]fileVal) []hmrUpdate {
+	base := c.getPublicURLBase()
+	var updates []hmrUpdate
+	for name, newOutput := range newOutputs {
+		oldOutput, ok := oldOutputs[name]
+		if !ok || oldOutput.Val == newOutput.Val {
+			continue
+		}
+		updates = append(updates, hmrUpdate{
+			Name:       name,
+			OldURL:     base + oldOutput.Val,
+			NewURL:     base + newOutput.Val,
+			HashedName: newOutput.Val,
+		})
+	}
+	sort.Slice(updates, func(i, j int) bool { return updates[i].Name < updates[j].Name })
+	return updates
+}
+
+// getHMRPayload returns nil if the browser should fall back to a hard reload.
+func (c *Config) getHMRPayload(oldOutputs map[string]fileVal) *refreshFilePayload {
+	if oldOutputs == nil {
+		return nil
+	}
+	newOutputs, err := c.loadScriptsFileRef()
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error reading scripts file ref: %v", err))
+		return nil
+	}
+	updates := c.getHMRUpdates(oldOutputs, newOutputs)
+	if len(updates) == 0 {
+		return nil
+	}
+	return &refreshFilePayload{ChangeType: changeTypeHMR, HMRUpdates: updates}
+}
@@ -0,0 +1,86 @@
+package ik
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHMRScriptBuild(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+	defer resetEnv()
+
+	env.createTestFile(t, "client/main.ts", "console.log('main');\nif (import.meta.hot) { import.meta.hot.accept(); console.log('hot only'); }")
+	env.config.cleanSources.ScriptEntries = map[string]string{
+		"main": filepath.Join(testRootDir, "client/main.ts"),
+	}
+
+	readMain := func() string {
+		t.Helper()
+		if err := env.config.SetupDistDir(); err != nil {
+			t.Fatalf("SetupDistDir() error = %v", err)
+		}
+		if err := env.config.handlePublicFiles(false); err != nil {
+			t.Fatalf("handlePublicFiles() error = %v", err)
+		}
+		if err := env.config.processScripts(); err != nil {
+			t.Fatalf("processScripts() error = %v", err)
+		}
+		outputs, err := env.config.loadScriptsFileRef()
+		if err != nil {
+			t.Fatalf("loadScriptsFileRef() error = %v", err)
+		}
+		content, err := os.ReadFile(filepath.Join(testRootDir, "dist/kiruna/static/public", outputs["main"].Val))
+		if err != nil {
+			t.Fatalf("Failed to read script output: %v", err)
+		}
+		return string(content)
+	}
+
+	// In prod, HMR code is dropped entirely
+	if content := readMain(); strings.Contains(content, "hot only") || strings.Contains(content, hmrContextIdentifier) {
+		t.Errorf("prod script output contains HMR code: %s", content)
+	}
+
+	env.config.setModeToDev()
+	content := readMain()
+	if !strings.Contains(content, hmrBannerJS) {
+		t.Errorf("dev script output is missing the HMR context banner: %s", content)
+	}
+	if !strings.Contains(content, hmrContextIdentifier+".accept()") {
+		t.Errorf("dev script output does not resolve import.meta.hot to the HMR context: %s", content)
+	}
+}
+
+func TestGetHMRUpdates(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	oldOutputs := map[string]fileVal{
+		"main":  {Val: "main_kiruna_aaa.js"},
+		"admin": {Val: "admin_kiruna_aaa.js"},
+	}
+	newOutputs := map[string]fileVal{
+		"main":  {Val: "main_kiruna_bbb.js"},
+		"admin": {Val: "admin_kiruna_aaa.js"},
+		"extra": {Val: "extra_kiruna_bbb.js"},
+	}
+
+	updates := env.config.getHMRUpdates(oldOutputs, newOutputs)
+	base := env.config.getPublicURLBase()
+	want := hmrUpdate{
+		Name:       "main",
+		OldURL:     base + "main_kiruna_aaa.js",
+		NewURL:     base + "main_kiruna_bbb.js",
+		HashedName: "main_kiruna_bbb.js",
+	}
+	if len(updates) != 1 || updates[0] != want {
+		t.Errorf("getHMRUpdates() = %+v, want [%+v]", updates, want)
+	}
+
+	if rfp := env.config.getHMRPayload(nil); rfp != nil {
+		t.Errorf("getHMRPayload(nil) = %+v, want nil", rfp)
+	}
+}
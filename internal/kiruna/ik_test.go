@@ -61,16 +61,22 @@ func setupTestEnv(t *testing.T) *testEnv {
 
 	// Initialize safecache
 	c.runtimeCache = runtimeCache{
-		baseFS:                safecache.New(c.getInitialBaseFS, nil),
-		baseDirFS:             safecache.New(c.getInitialBaseDirFS, nil),
-		publicFS:              safecache.New(func() (fs.FS, error) { return c.getSubFSPublic() }, nil),
-		privateFS:             safecache.New(func() (fs.FS, error) { return c.getSubFSPrivate() }, nil),
-		styleSheetLinkElement: safecache.New(c.getInitialStyleSheetLinkElement, GetIsDev),
-		styleSheetURL:         safecache.New(c.getInitialStyleSheetURL, GetIsDev),
-		criticalCSS:           safecache.New(c.getInitialCriticalCSSStatus, GetIsDev),
-		publicFileMapFromGob:  safecache.New(c.getInitialPublicFileMapFromGobRuntime, nil),
-		publicFileMapURL:      safecache.New(c.getInitialPublicFileMapURL, GetIsDev),
-		publicURLs:            safecache.NewMap(c.getInitialPublicURL, publicURLsKeyMaker, nil),
+		baseFS:                  safecache.New(c.getInitialBaseFS, nil),
+		baseDirFS:               safecache.New(c.getInitialBaseDirFS, nil),
+		publicFS:                safecache.New(func() (fs.FS, error) { return c.getSubFSPublic() }, nil),
+		privateFS:               safecache.New(func() (fs.FS, error) { return c.getSubFSPrivate() }, nil),
+		styleSheetLinkElement:   safecache.New(c.getInitialStyleSheetLinkElement, c.getIsDev),
+		styleSheetURL:           safecache.New(c.getInitialStyleSheetURL, c.getIsDev),
+		criticalCSS:             safecache.New(c.getInitialCriticalCSSStatus, c.getIsDev),
+		publicFileMapFromGob:    safecache.New(c.getInitialPublicFileMapFromGobRuntime, nil),
+		publicFileMapURL:        safecache.New(c.getInitialPublicFileMapURL, c.getIsDev),
+		publicFileMapDetails:    safecache.New(c.getInitialPublicFileMapDetails, c.getIsDev),
+		publicURLs:              safecache.NewMap(c.getInitialPublicURL, publicURLsKeyMaker, nil),
+		templates:               safecache.New(c.getInitialTemplates, c.getIsDev),
+		cssPreloadURLs:          safecache.New(c.getInitialCSSPreloadURLs, c.getIsDev),
+		publicFileMapByDistName: safecache.New(c.getInitialPublicFileMapByDistName, nil),
+		staticCacheEntries:      safecache.New(c.getInitialStaticCacheEntries, nil),
+		preloadLinks:            safecache.New(c.getInitialPreloadLinks, c.getIsDev),
 	}
 
 	// Initialize dev cache if needed
@@ -117,6 +123,9 @@ func resetEnv() {
 	os.Unsetenv(portHasBeenSetKey)
 	os.Unsetenv(refreshServerPortKey)
 	os.Unsetenv(isBuildTimeKey)
+	os.Unsetenv(useDevProxyKey)
+	os.Unsetenv(devProxyInjectsKey)
+	os.Unsetenv(appEventsURLKey)
 }
 
 func TestMain(m *testing.M) {
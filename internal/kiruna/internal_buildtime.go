@@ -0,0 +1,35 @@
+package ik
+
+import (
+	"sync"
+
+	esbuild "github.com/evanw/esbuild/pkg/api"
+)
+
+type buildtime struct {
+	cssImportURLsMu         sync.RWMutex
+	criticalReliedUponFiles map[string]struct{}
+	normalReliedUponFiles   map[string]struct{}
+	cssPreloadURLs          map[string][]string // keyed by CSS nature
+	esbuildCtxCritical      esbuildCtxSafe
+	esbuildCtxNormal        esbuildCtxSafe
+	scriptReliedUponFilesMu sync.RWMutex
+	scriptReliedUponFiles   map[string]struct{}
+	esbuildCtxScripts       esbuildCtxSafe
+	staticFilesIgnoreList   map[string]struct{}
+	buildReportMu           sync.Mutex
+	cssBundleRecords        map[string]*cssBundleRecord
+	esbuildWarnings         map[string][]esbuild.Message
+	goBuildReport           *BuildGoReport // From the most recent compileBinary
+	generatedPublicMu       sync.Mutex
+}
+
+// __TODO this should probably be a config option and use glob patterns
+var defaultStaticFilesIgnoreList = []string{".DS_Store"}
+
+func (c *Config) buildtimeInit() {
+	c.staticFilesIgnoreList = make(map[string]struct{}, len(defaultStaticFilesIgnoreList))
+	for _, name := range defaultStaticFilesIgnoreList {
+		c.staticFilesIgnoreList[name] = struct{}{}
+	}
+}
@@ -1,10 +1,13 @@
 package ik
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/sjc5/kit/pkg/safecache"
@@ -17,7 +20,14 @@ type withMu[T any] struct {
 }
 
 type dev struct {
-	initOnce               sync.Once
+	devCtx                 context.Context // Cancelled once the dev server starts shutting down
+	devIsRunning           atomic.Bool
+	isDevMode              atomic.Bool
+	hasExplicitMode        atomic.Bool // If true, isDevMode wins over KIRUNA_MODE
+	port                   int
+	refreshServerPort      int
+	useDevProxy            bool
+	devProxyInjectsScript  bool
 	watcher                *fsnotify.Watcher
 	manager                *clientManager
 	fileSemaphore          *semaphore.Weighted
@@ -27,55 +37,89 @@ type dev struct {
 	defaultWatchedFile     *WatchedFile
 	defaultWatchedFiles    *[]WatchedFile
 	lastBuildCmd           withMu[*exec.Cmd]
+	lastBuildDone          chan struct{}                       // Guarded by lastBuildCmd.mu. Closed once lastBuildCmd has exited.
+	goBinaryProcesses      withMu[map[string]*goBinaryProcess] // Keyed by GoBinary.Name
+	sidecars               withMu[map[string]*sidecarProcess]  // Keyed by DevSidecar.Name (nil when stopped)
+	sidecarsGen            uint64                              // Guarded by sidecars.mu. Bumped on every start and stop.
+	sidecarRestarts        map[string]*time.Timer              // Guarded by sidecars.mu. Pending restarts, keyed by DevSidecar.Name.
+	appPortBehindProxy     int
 	matchResults           *safecache.CacheMap[potentialMatch, string, bool]
+	appEvents              *appEventsHub
+	goDeps                 withMu[map[string]*goDepGraph] // Keyed by entry, and loaded lazily (see getIsGoFileInGraph)
+
+	// App process side of appEvents
+	appEventCallbacks   withMu[[]func()]
+	appEventsListenOnce sync.Once
 }
 
-func (c *Config) devInitOnce() {
-	c.dev.initOnce.Do(func() {
-		// watcher
-		watcher, err := fsnotify.NewWatcher()
-		if err != nil {
-			errMsg := fmt.Sprintf("error: failed to create watcher: %v", err)
-			c.Logger.Error(errMsg)
-			panic(errMsg)
-		}
-		c.watcher = watcher
+// devInit (re)initializes all dev state. Called on every StartDev, as a
+// stopped dev server leaves behind a closed watcher and a stopped manager.
+func (c *Config) devInit() error {
+	// watcher
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error: failed to create watcher: %w", err)
+	}
+	c.watcher = watcher
+
+	// manager
+	c.manager = newClientManager()
+	c.manager.onClientError = c.logClientError
+	c.manager.forwardConsoleErrors = c.devConfig.ForwardConsoleErrors
+	c.manager.isAllowedOrigin = c.isLocalDevOrigin
+	c.appEvents = newAppEventsHub()
+	c.appEvents.isAllowedOrigin = c.isLocalDevOrigin
+	c.invalidateGoDepGraph()
+
+	// fileSemaphore
+	c.fileSemaphore = semaphore.NewWeighted(100)
 
-		// manager
-		c.manager = newClientManager()
+	// ignored setup
+	c.ignoredDirPatterns = &[]string{}
+	c.ignoredFilePatterns = &[]string{}
+	c.naiveIgnoreDirPatterns = &[]string{
+		"**/.git",
+		"**/node_modules",
+		c.__dist.S().Bin.FullPath(),
+		c.__dist.S().Kiruna.FullPath(),
+		filepath.Join(c.cleanSources.PublicStatic, noHashPublicDirsByVersion[0]),
+		filepath.Join(c.cleanSources.PublicStatic, noHashPublicDirsByVersion[1]),
+	}
+	for _, p := range *c.naiveIgnoreDirPatterns {
+		*c.ignoredDirPatterns = append(*c.ignoredDirPatterns, filepath.Join(c.cleanWatchRoot, p))
+	}
+	for _, p := range c.devConfig.IgnorePatterns.Dirs {
+		*c.ignoredDirPatterns = append(*c.ignoredDirPatterns, filepath.Join(c.cleanWatchRoot, p))
+	}
+	for _, p := range c.devConfig.IgnorePatterns.Files {
+		*c.ignoredFilePatterns = append(*c.ignoredFilePatterns, filepath.Join(c.cleanWatchRoot, p))
+	}
 
-		// fileSemaphore
-		c.fileSemaphore = semaphore.NewWeighted(100)
+	// The app events hub lives on the refresh server or dev proxy, neither of
+	// which runs in ServerOnly mode
+	hotReloadPrivateFiles := c.devConfig.HotReloadPrivateFiles
+	if hotReloadPrivateFiles && c.ServerOnly {
+		c.Logger.Warn("kiruna.DevConfig.HotReloadPrivateFiles is ignored when kiruna.Config.ServerOnly is true")
+		hotReloadPrivateFiles = false
+	}
 
-		// ignored setup
-		c.ignoredDirPatterns = &[]string{}
-		c.ignoredFilePatterns = &[]string{}
-		c.naiveIgnoreDirPatterns = &[]string{
-			"**/.git",
-			"**/node_modules",
-			c.__dist.S().Bin.FullPath(),
-			c.__dist.S().Kiruna.FullPath(),
-			filepath.Join(c.cleanSources.PublicStatic, noHashPublicDirsByVersion[0]),
-			filepath.Join(c.cleanSources.PublicStatic, noHashPublicDirsByVersion[1]),
-		}
-		for _, p := range *c.naiveIgnoreDirPatterns {
-			*c.ignoredDirPatterns = append(*c.ignoredDirPatterns, filepath.Join(c.cleanWatchRoot, p))
-		}
-		for _, p := range c.devConfig.IgnorePatterns.Dirs {
-			*c.ignoredDirPatterns = append(*c.ignoredDirPatterns, filepath.Join(c.cleanWatchRoot, p))
-		}
-		for _, p := range c.devConfig.IgnorePatterns.Files {
-			*c.ignoredFilePatterns = append(*c.ignoredFilePatterns, filepath.Join(c.cleanWatchRoot, p))
-		}
+	// default watched files
+	c.defaultWatchedFile = &WatchedFile{}
+	c.defaultWatchedFiles = &[]WatchedFile{
+		{
+			Pattern:                fmt.Sprintf("%s/**/*", c.cleanSources.PrivateStatic),
+			RestartApp:             !hotReloadPrivateFiles,
+			hotReloadsPrivateFiles: hotReloadPrivateFiles,
+		},
+		{
+			Pattern:             fmt.Sprintf("%s/**/*", c.cleanSources.PublicStatic),
+			RestartApp:          !c.devConfig.HotSwapPublicFiles,
+			hotSwapsPublicFiles: c.devConfig.HotSwapPublicFiles,
+		},
+	}
 
-		// default watched files
-		c.defaultWatchedFile = &WatchedFile{}
-		c.defaultWatchedFiles = &[]WatchedFile{
-			{Pattern: fmt.Sprintf("%s/**/*", c.cleanSources.PrivateStatic), RestartApp: true},
-			{Pattern: fmt.Sprintf("%s/**/*", c.cleanSources.PublicStatic), RestartApp: true},
-		}
+	// matches
+	c.matchResults = safecache.NewMap(c.getInitialMatchResults, c.matchResultsKeyMaker, nil)
 
-		// matches
-		c.matchResults = safecache.NewMap(c.getInitialMatchResults, c.matchResultsKeyMaker, nil)
-	})
+	return nil
 }
@@ -14,8 +14,23 @@ func (c *Config) Private_CommonInitOnce_OnlyCallInNewFunc() {
 			PublicStatic:     filepath.Clean(c.PublicStaticDir),
 			CriticalCSSEntry: filepath.Clean(c.CriticalCSSEntry),
 			NormalCSSEntry:   filepath.Clean(c.NormalCSSEntry),
+			ScriptEntries:    make(map[string]string, len(c.ScriptEntries)),
+		}
+
+		for name, entry := range c.ScriptEntries {
+			c.cleanSources.ScriptEntries[name] = filepath.Clean(entry)
 		}
 
 		c.__dist = toDistLayout(c.cleanSources.Dist)
+
+		c.publicPathPrefix = "/" + PUBLIC + "/"
+		if c.PublicPathPrefix != "" {
+			c.publicPathPrefix = withTrailingSlash(c.PublicPathPrefix)
+		}
+		if c.PublicAssetBaseURL != "" {
+			c.publicAssetBaseURL = withTrailingSlash(c.PublicAssetBaseURL)
+		}
+
+		c.buildtimeInit()
 	})
 }
@@ -11,6 +11,7 @@ import (
 type runtime struct {
 	initOnce     sync.Once
 	runtimeCache runtimeCache
+	mustGetPort  withMu[int] // Memoized result of Config.MustGetPort
 }
 
 type runtimeCache struct {
@@ -25,34 +26,62 @@ type runtimeCache struct {
 	styleSheetURL         *safecache.Cache[string]
 	criticalCSS           *safecache.Cache[*criticalCSSStatus]
 
+	// Scripts
+	scriptElements *safecache.Cache[template.HTML]
+
+	// Templates
+	templates *safecache.Cache[*template.Template]
+
 	// Public URLs
 	publicFileMapFromGob *safecache.Cache[FileMap]
 	publicFileMapURL     *safecache.Cache[string]
 	publicFileMapDetails *safecache.Cache[*publicFileMapDetails]
 	publicURLs           *safecache.CacheMap[string, string, string]
+
+	// Static handler
+	publicFileMapByDistName *safecache.Cache[map[string]publicDistFile]
+	staticCacheEntries      *safecache.Cache[map[string]*staticCacheEntry] // Keyed by served name
+
+	// Preloads
+	cssPreloadURLs *safecache.Cache[[]string]
+	preloadLinks   *safecache.Cache[[]string]
 }
 
 func (c *Config) Private_RuntimeInitOnce_OnlyCallInNewFunc() {
 	c.runtime.initOnce.Do(func() {
 		c.runtimeCache = runtimeCache{
 			// FS
-			baseFS:    safecache.New(c.getInitialBaseFS, GetIsDev),
-			baseDirFS: safecache.New(c.getInitialBaseDirFS, GetIsDev),
-			publicFS:  safecache.New(func() (fs.FS, error) { return c.getSubFSPublic() }, GetIsDev),
-			privateFS: safecache.New(func() (fs.FS, error) { return c.getSubFSPrivate() }, GetIsDev),
+			baseFS:    safecache.New(c.getInitialBaseFS, c.getIsDev),
+			baseDirFS: safecache.New(c.getInitialBaseDirFS, c.getIsDev),
+			publicFS:  safecache.New(func() (fs.FS, error) { return c.getSubFSPublic() }, c.getIsDev),
+			privateFS: safecache.New(func() (fs.FS, error) { return c.getSubFSPrivate() }, c.getIsDev),
 
 			// CSS
-			styleSheetLinkElement: safecache.New(c.getInitialStyleSheetLinkElement, GetIsDev),
-			styleSheetURL:         safecache.New(c.getInitialStyleSheetURL, GetIsDev),
-			criticalCSS:           safecache.New(c.getInitialCriticalCSSStatus, GetIsDev),
+			styleSheetLinkElement: safecache.New(c.getInitialStyleSheetLinkElement, c.getIsDev),
+			styleSheetURL:         safecache.New(c.getInitialStyleSheetURL, c.getIsDev),
+			criticalCSS:           safecache.New(c.getInitialCriticalCSSStatus, c.getIsDev),
+
+			// Scripts
+			scriptElements: safecache.New(c.getInitialScriptElements, c.getIsDev),
+
+			// Templates
+			templates: safecache.New(c.getInitialTemplates, c.getIsDev),
 
 			// Public URLs
-			publicFileMapFromGob: safecache.New(c.getInitialPublicFileMapFromGobRuntime, GetIsDev),
-			publicFileMapURL:     safecache.New(c.getInitialPublicFileMapURL, GetIsDev),
-			publicFileMapDetails: safecache.New(c.getInitialPublicFileMapDetails, GetIsDev),
+			publicFileMapFromGob: safecache.New(c.getInitialPublicFileMapFromGobRuntime, c.getIsDev),
+			publicFileMapURL:     safecache.New(c.getInitialPublicFileMapURL, c.getIsDev),
+			publicFileMapDetails: safecache.New(c.getInitialPublicFileMapDetails, c.getIsDev),
 			publicURLs: safecache.NewMap(c.getInitialPublicURL, publicURLsKeyMaker, func(string) bool {
-				return GetIsDev()
+				return c.getIsDev()
 			}),
+
+			// Static handler
+			publicFileMapByDistName: safecache.New(c.getInitialPublicFileMapByDistName, c.getIsDev),
+			staticCacheEntries:      safecache.New(c.getInitialStaticCacheEntries, c.getIsDev),
+
+			// Preloads
+			cssPreloadURLs: safecache.New(c.getInitialCSSPreloadURLs, c.getIsDev),
+			preloadLinks:   safecache.New(c.getInitialPreloadLinks, c.getIsDev),
 		}
 	})
 }
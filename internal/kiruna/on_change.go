@@ -63,8 +63,8 @@ func (c *Config) runConcurrentOnChangeCallbacks(onChanges *[]OnChange, evtName s
 			eg.Go(func() error {
 				err := o.Func()
 				if err != nil {
-					c.Logger.Error(fmt.Sprintf("error running extension callback: %v", err))
-					return err
+					c.Logger.Error(fmt.Sprintf("error running extension callback (%s): %v", o.getName(), err))
+					return &onChangeCallbackError{name: o.getName(), err: err}
 				}
 				return nil
 			})
@@ -92,8 +92,8 @@ func (c *Config) simpleRunOnChangeCallbacks(onChanges *[]OnChange, evtName strin
 		}
 		err := o.Func()
 		if err != nil {
-			c.Logger.Error(fmt.Sprintf("error running extension callback: %v", err))
-			return err
+			c.Logger.Error(fmt.Sprintf("error running extension callback (%s): %v", o.getName(), err))
+			return &onChangeCallbackError{name: o.getName(), err: err}
 		}
 	}
 	return nil
@@ -10,12 +10,36 @@ const (
 	defaultFreePort = 10_000
 )
 
+var legacyPort withMu[int] // See MustGetPort
+
+// MustGetPort returns the port your app should listen on. In dev, it is the
+// port the dev server picked (passed down via PORT). If your app runs in dev
+// mode without the dev server, a free port is found once and reused.
+func (c *Config) MustGetPort() int {
+	c.runtime.mustGetPort.mu.Lock()
+	defer c.runtime.mustGetPort.mu.Unlock()
+	if c.runtime.mustGetPort.v == 0 {
+		c.runtime.mustGetPort.v = resolvePort(c.getIsDev())
+	}
+	return c.runtime.mustGetPort.v
+}
+
+// Deprecated: Use Kiruna.MustGetPort instead, which respects the mode of
+// your Kiruna instance. This reads KIRUNA_MODE from the env, and memoizes its
+// result for the whole process, but no longer writes to the env.
 func MustGetPort() int {
-	isDev := GetIsDev()
-	portHasBeenSet := getPortHasBeenSet()
+	legacyPort.mu.Lock()
+	defer legacyPort.mu.Unlock()
+	if legacyPort.v == 0 {
+		legacyPort.v = resolvePort(GetIsDev())
+	}
+	return legacyPort.v
+}
+
+func resolvePort(isDev bool) int {
 	defaultPort := getPort()
 
-	if !isDev || portHasBeenSet {
+	if !isDev || getPortHasBeenSet() {
 		return defaultPort
 	}
 
@@ -23,9 +47,5 @@ func MustGetPort() int {
 	if err != nil {
 		log.Panicf("error: failed to get free port: %v", err)
 	}
-
-	setPort(port)
-	setPortHasBeenSet()
-
 	return port
 }
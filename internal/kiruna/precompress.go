@@ -0,0 +1,271 @@
+package ik
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	goruntime "runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/sync/errgroup"
+)
+
+const defaultPrecompressMinBytes = 1024
+
+type precompressedEncoding struct {
+	name     string // Content-Encoding token
+	ext      string // sibling file extension
+	compress func(dst io.Writer, src []byte) error
+}
+
+// Ordered by server preference (used to break ties between equal q-values)
+var precompressedEncodings = []precompressedEncoding{
+	{
+		name: "br",
+		ext:  ".br",
+		compress: func(dst io.Writer, src []byte) error {
+			w := brotli.NewWriterLevel(dst, brotli.BestCompression)
+			if _, err := w.Write(src); err != nil {
+				return err
+			}
+			return w.Close()
+		},
+	},
+	{
+		name: "gzip",
+		ext:  ".gz",
+		compress: func(dst io.Writer, src []byte) error {
+			w, err := gzip.NewWriterLevel(dst, gzip.BestCompression)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(src); err != nil {
+				return err
+			}
+			return w.Close()
+		},
+	},
+}
+
+var compressibleExts = map[string]struct{}{
+	".css":  {},
+	".htm":  {},
+	".html": {},
+	".js":   {},
+	".json": {},
+	".map":  {},
+	".mjs":  {},
+	".otf":  {},
+	".svg":  {},
+	".ttf":  {},
+	".txt":  {},
+	".wasm": {},
+	".xml":  {},
+}
+
+func getIsCompressible(name string) bool {
+	_, ok := compressibleExts[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+func (c *Config) getPrecompressMinBytes() int64 {
+	if c.PrecompressMinBytes > 0 {
+		return int64(c.PrecompressMinBytes)
+	}
+	return defaultPrecompressMinBytes
+}
+
+// precompressPublicFiles writes gzip and brotli siblings next to every
+// compressible file in the public dist dir that meets the size threshold.
+// Skipped in dev, where the handler simply falls back to the raw files.
+func (c *Config) precompressPublicFiles() error {
+	if !c.PrecompressPublicFiles || c.getIsDev() {
+		return nil
+	}
+
+	publicDistDir := c.__dist.S().Kiruna.S().Static.S().Public.FullPath()
+	minBytes := c.getPrecompressMinBytes()
+
+	var eg errgroup.Group
+	eg.SetLimit(goruntime.NumCPU())
+
+	err := filepath.WalkDir(publicDistDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !getIsCompressible(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() < minBytes {
+			return nil
+		}
+		eg.Go(func() error {
+			return precompressFile(path)
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking public dist dir: %v", err)
+	}
+
+	return eg.Wait()
+}
+
+func precompressFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading file to precompress: %v", err)
+	}
+
+	for _, enc := range precompressedEncodings {
+		siblingPath := path + enc.ext
+
+		// Respect any precompressed sibling shipped as-is in the public dir
+		if _, err := os.Stat(siblingPath); err == nil {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := enc.compress(&buf, content); err != nil {
+			return fmt.Errorf("error compressing %s (%s): %v", path, enc.name, err)
+		}
+
+		// Not worth serving if it didn't get any smaller
+		if buf.Len() >= len(content) {
+			continue
+		}
+
+		if err := os.WriteFile(siblingPath, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("error writing precompressed file: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// getAcceptableEncodings parses an Accept-Encoding header and returns the
+// supported precompressed encodings the client accepts, best first.
+func getAcceptableEncodings(acceptEncoding string) []precompressedEncoding {
+	qValues := make(map[string]float64)
+	wildcardQ := -1.0
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		token, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		token = strings.ToLower(strings.TrimSpace(token))
+		if token == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, val, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.TrimSpace(key) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if token == "*" {
+			wildcardQ = q
+			continue
+		}
+		qValues[token] = q
+	}
+
+	type candidate struct {
+		enc precompressedEncoding
+		q   float64
+	}
+	var candidates []candidate
+
+	for _, enc := range precompressedEncodings {
+		q, ok := qValues[enc.name]
+		if !ok {
+			q = wildcardQ
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{enc: enc, q: q})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+
+	encodings := make([]precompressedEncoding, 0, len(candidates))
+	for _, cand := range candidates {
+		encodings = append(encodings, cand.enc)
+	}
+	return encodings
+}
+
+// newPrecompressedFileServer serves a precompressed sibling of the requested
+// file when one exists in publicFS and the client accepts its encoding.
+// Everything else is delegated to next. Expects the path prefix to already
+// be stripped from the request URL.
+func newPrecompressedFileServer(publicFS fs.FS, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" || !getIsCompressible(name) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		for _, enc := range getAcceptableEncodings(r.Header.Get("Accept-Encoding")) {
+			if serveFileFromFS(w, r, publicFS, name+enc.ext, name, enc.name) {
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveFileFromFS serves the file at fsPath, with the Content-Type derived
+// from contentName. Returns false (having written nothing) if the file
+// can't be served, so that the caller can fall back to something else.
+func serveFileFromFS(w http.ResponseWriter, r *http.Request, fsys fs.FS, fsPath, contentName, contentEncoding string) bool {
+	f, err := fsys.Open(fsPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		return false
+	}
+
+	if contentEncoding != "" {
+		w.Header().Set("Content-Encoding", contentEncoding)
+		if etag := w.Header().Get("ETag"); etag != "" {
+			w.Header().Set("ETag", withETagEncoding(etag, contentEncoding))
+		}
+	}
+	http.ServeContent(w, r, contentName, info.ModTime(), rs)
+	return true
+}
@@ -0,0 +1,137 @@
+package ik
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetAcceptableEncodings(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []string
+	}{
+		{"Empty", "", nil},
+		{"GzipOnly", "gzip", []string{"gzip"}},
+		{"Both", "gzip, deflate, br", []string{"br", "gzip"}},
+		{"QValues", "br;q=0.5, gzip;q=0.8", []string{"gzip", "br"}},
+		{"Refused", "br;q=0, gzip", []string{"gzip"}},
+		{"Wildcard", "*", []string{"br", "gzip"}},
+		{"WildcardWithRefusal", "*, br;q=0", []string{"gzip"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, enc := range getAcceptableEncodings(tt.header) {
+				got = append(got, enc.name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("getAcceptableEncodings(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrecompressPublicFiles(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.config.PrecompressPublicFiles = true
+
+	bigCSS := strings.Repeat("body { color: red; }\n", 200)
+	env.createTestFile(t, "dist/kiruna/static/public/big.css", bigCSS)
+	env.createTestFile(t, "dist/kiruna/static/public/small.css", "p{}")
+	env.createTestFile(t, "dist/kiruna/static/public/image.png", strings.Repeat("x", 4096))
+
+	if err := env.config.precompressPublicFiles(); err != nil {
+		t.Fatalf("precompressPublicFiles() error = %v", err)
+	}
+
+	publicDir := filepath.Join(testRootDir, "dist/kiruna/static/public")
+
+	for _, name := range []string{"big.css.gz", "big.css.br"} {
+		if _, err := os.Stat(filepath.Join(publicDir, name)); err != nil {
+			t.Errorf("Expected %s to exist: %v", name, err)
+		}
+	}
+	for _, name := range []string{"small.css.gz", "small.css.br", "image.png.gz", "image.png.br"} {
+		if _, err := os.Stat(filepath.Join(publicDir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to exist", name)
+		}
+	}
+
+	// Only files in the public file map get ETags
+	if err := os.MkdirAll(filepath.Join(publicDir, "kiruna_internal__"), 0755); err != nil {
+		t.Fatalf("Failed to create public internal dir: %v", err)
+	}
+	if err := env.config.savePublicFileMap(FileMap{
+		"big.css": {Val: "big.css", IsPrehashed: true, Integrity: getSRIDigest([]byte(bigCSS))},
+	}); err != nil {
+		t.Fatalf("savePublicFileMap() error = %v", err)
+	}
+
+	handler, err := env.config.GetServeStaticHandler("/public/", false)
+	if err != nil {
+		t.Fatalf("GetServeStaticHandler() error = %v", err)
+	}
+
+	t.Run("Gzip", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/public/big.css", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("Content-Encoding = %q, want gzip", got)
+		}
+		if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("Vary = %q, want Accept-Encoding", got)
+		}
+		if got := rec.Header().Get("ETag"); !strings.HasSuffix(got, `.gzip"`) {
+			t.Errorf("ETag = %q, want a gzip-specific ETag", got)
+		}
+		if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/css") {
+			t.Errorf("Content-Type = %q, want text/css", got)
+		}
+		gz, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		if err != nil {
+			t.Fatalf("gzip.NewReader() error = %v", err)
+		}
+		decoded, _ := io.ReadAll(gz)
+		if string(decoded) != bigCSS {
+			t.Errorf("Decoded body does not match original")
+		}
+	})
+
+	t.Run("Brotli", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/public/big.css", nil)
+		req.Header.Set("Accept-Encoding", "gzip, br")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Encoding"); got != "br" {
+			t.Errorf("Content-Encoding = %q, want br", got)
+		}
+	})
+
+	t.Run("Identity", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/public/big.css", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("Content-Encoding = %q, want none", got)
+		}
+		if rec.Body.String() != bigCSS {
+			t.Errorf("Body does not match original")
+		}
+	})
+}
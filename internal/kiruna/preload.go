@@ -0,0 +1,284 @@
+package ik
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type PreloadOptions struct {
+	// If true, PreloadMiddleware sends a 103 Early Hints response carrying the
+	// Link headers before calling the wrapped handler, for requests that accept
+	// HTML. Note that some proxies and older HTTP/1.1 clients mishandle 1xx
+	// responses.
+	EarlyHints bool
+
+	// Optional. Returns extra public files to preload for the request, by
+	// original public path (e.g., "images/hero.webp"), in addition to the
+	// Kiruna-managed assets. Paths are resolved through GetPublicURL.
+	GetRoutePreloads func(r *http.Request) []string
+}
+
+// Font files referenced via url() anywhere in the CSS @import graph.
+// Other url() assets (e.g., background images) may never be needed by
+// a given page, so they aren't worth preloading by default.
+var cssPreloadableExts = map[string]bool{
+	".woff2": true,
+	".woff":  true,
+	".ttf":   true,
+	".otf":   true,
+}
+
+// getCSSPreloadURLs returns the (already resolved) URLs of preloadable
+// files referenced by a CSS bundle, sorted.
+func getCSSPreloadURLs(metafile *Metafile) []string {
+	seen := map[string]bool{}
+	urls := []string{}
+	for _, input := range metafile.Inputs {
+		for _, imp := range input.Imports {
+			if imp.Kind != "url-token" || seen[imp.Path] {
+				continue
+			}
+			if !cssPreloadableExts[strings.ToLower(path.Ext(strings.SplitN(imp.Path, "?", 2)[0]))] {
+				continue
+			}
+			seen[imp.Path] = true
+			urls = append(urls, imp.Path)
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// saveCSSPreloadURLsLocked must be called with cssImportURLsMu held.
+func (c *Config) saveCSSPreloadURLsLocked(nature string, urls []string) error {
+	if c.cssPreloadURLs == nil {
+		c.cssPreloadURLs = make(map[string][]string)
+	}
+	c.cssPreloadURLs[nature] = urls
+
+	preloadsBytes, err := json.Marshal(c.cssPreloadURLs)
+	if err != nil {
+		return fmt.Errorf("error marshalling CSS preloads: %v", err)
+	}
+	preloadsPath := c.__dist.S().Kiruna.S().Internal.S().CSSPreloadsDotJSON.FullPath()
+	if err := os.MkdirAll(filepath.Dir(preloadsPath), 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %v", err)
+	}
+	if err := os.WriteFile(preloadsPath, preloadsBytes, 0644); err != nil {
+		return fmt.Errorf("error writing CSS preloads: %v", err)
+	}
+	return nil
+}
+
+func (c *Config) getInitialCSSPreloadURLs() ([]string, error) {
+	baseFS, err := c.GetBaseFS()
+	if err != nil {
+		return nil, fmt.Errorf("error getting FS: %v", err)
+	}
+
+	distKirunaInternal := c.__dist.S().Kiruna.S().Internal
+
+	// __LOCATION_ASSUMPTION: Inside "dist/kiruna"
+	content, err := fs.ReadFile(baseFS, path.Join(
+		distKirunaInternal.LastSegment(),
+		distKirunaInternal.S().CSSPreloadsDotJSON.LastSegment(),
+	))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("error reading CSS preloads: %v", err)
+	}
+
+	var byNature map[string][]string
+	if err := json.Unmarshal(content, &byNature); err != nil {
+		return nil, fmt.Errorf("error unmarshalling CSS preloads: %v", err)
+	}
+
+	seen := map[string]bool{}
+	urls := []string{}
+	for _, nature := range []string{"critical", "normal"} {
+		for _, url := range byNature[nature] {
+			if !seen[url] {
+				seen[url] = true
+				urls = append(urls, url)
+			}
+		}
+	}
+	return urls, nil
+}
+
+// getInitialPreloadLinks returns Link header values for every Kiruna-managed
+// asset an HTML page needs: the normal stylesheet, the public file map module,
+// and fonts referenced by CSS.
+func (c *Config) getInitialPreloadLinks() ([]string, error) {
+	links := []string{}
+	if c.ServerOnly {
+		return links, nil
+	}
+
+	// The stylesheet link element is rendered with crossorigin="anonymous",
+	// and the preload must match or the browser will fetch it twice.
+	if c.cleanSources.NormalCSSEntry != "" {
+		if url := c.GetStyleSheetURL(); url != "" {
+			links = append(links, toPreloadLink(url, "preload", "style", true))
+		}
+	}
+
+	if url := c.GetPublicFileMapURL(); url != "" {
+		links = append(links, toPreloadLink(url, "modulepreload", "", false))
+	}
+
+	fontURLs, err := c.runtimeCache.cssPreloadURLs.Get()
+	if err != nil {
+		return nil, err
+	}
+	for _, url := range fontURLs {
+		links = append(links, toPreloadLink(url, "preload", "font", true))
+	}
+
+	return links, nil
+}
+
+// GetPreloadLinks returns Link header values preloading the Kiruna-managed
+// assets, plus the given public files (by original public path).
+func (c *Config) GetPreloadLinks(originalPublicURLs ...string) []string {
+	managed, err := c.runtimeCache.preloadLinks.Get()
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error getting preload links: %v", err))
+	}
+
+	links := make([]string, 0, len(managed)+len(originalPublicURLs))
+	links = append(links, managed...)
+	for _, originalPublicURL := range originalPublicURLs {
+		links = append(links, getPreloadLinkForPublicURL(c.GetPublicURL(originalPublicURL)))
+	}
+	return links
+}
+
+func getPreloadLinkForPublicURL(url string) string {
+	ext := strings.ToLower(path.Ext(strings.SplitN(url, "?", 2)[0]))
+	switch {
+	case ext == ".js" || ext == ".mjs":
+		return toPreloadLink(url, "modulepreload", "", false)
+	case ext == ".css":
+		return toPreloadLink(url, "preload", "style", false)
+	case cssPreloadableExts[ext]:
+		return toPreloadLink(url, "preload", "font", true)
+	case strings.HasPrefix(mime.TypeByExtension(ext), "image/"):
+		return toPreloadLink(url, "preload", "image", false)
+	default:
+		return toPreloadLink(url, "preload", "fetch", true)
+	}
+}
+
+func toPreloadLink(url, rel, as string, crossOrigin bool) string {
+	link := "<" + url + ">; rel=" + rel
+	if as != "" {
+		link += "; as=" + as
+	}
+	if crossOrigin {
+		link += "; crossorigin=anonymous"
+	}
+	return link
+}
+
+// PreloadMiddleware adds Link headers (see GetPreloadLinks) to HTML responses,
+// and optionally sends them early as a 103 Early Hints response.
+func (c *Config) PreloadMiddleware(opts *PreloadOptions) func(http.Handler) http.Handler {
+	if opts == nil {
+		opts = &PreloadOptions{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var routePreloads []string
+			if opts.GetRoutePreloads != nil {
+				routePreloads = opts.GetRoutePreloads(r)
+			}
+			links := c.GetPreloadLinks(routePreloads...)
+			if len(links) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if opts.EarlyHints && getIsHTMLNavigation(r) {
+				for _, link := range links {
+					w.Header().Add("Link", link)
+				}
+				w.WriteHeader(http.StatusEarlyHints)
+				// Only the final response's Content-Type tells us whether it is HTML
+				w.Header().Del("Link")
+			}
+
+			next.ServeHTTP(&preloadResponseWriter{ResponseWriter: w, links: links}, r)
+		})
+	}
+}
+
+func getIsHTMLNavigation(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// preloadResponseWriter adds Link headers just before the headers are
+// written, if the response turns out to be HTML.
+type preloadResponseWriter struct {
+	http.ResponseWriter
+	links       []string
+	wroteHeader bool
+}
+
+func (w *preloadResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader && statusCode >= 200 {
+		w.wroteHeader = true
+		w.maybeAddLinks(nil)
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *preloadResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.maybeAddLinks(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *preloadResponseWriter) maybeAddLinks(firstChunk []byte) {
+	contentType := w.Header().Get("Content-Type")
+	if contentType == "" && firstChunk != nil {
+		// Mirrors what net/http will sniff on the first write
+		contentType = http.DetectContentType(firstChunk)
+	}
+	if !strings.HasPrefix(contentType, "text/html") {
+		return
+	}
+	for _, link := range w.links {
+		w.Header().Add("Link", link)
+	}
+}
+
+func (w *preloadResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.maybeAddLinks(nil)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *preloadResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
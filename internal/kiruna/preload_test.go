@@ -0,0 +1,95 @@
+package ik
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestPreloadMiddleware(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "public-static/fonts/inter.woff2", "font")
+	env.createTestFile(t, "public-static/images/hero.webp", "image")
+	env.createTestFile(t, "public-static/bg.png", "png")
+	env.createTestFile(t, "styles/fonts.css", "@font-face { font-family: Inter; src: url('/fonts/inter.woff2'); }")
+	env.createTestFile(t, "critical.css", "body { color: red; }")
+	env.createTestFile(t, "main.css", "@import './styles/fonts.css';\nbody { background: url('/bg.png'); }")
+
+	if _, err := env.config.Build(false, false); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	fontURL := env.config.GetPublicURL("fonts/inter.woff2")
+	heroURL := env.config.GetPublicURL("images/hero.webp")
+
+	mw := env.config.PreloadMiddleware(&PreloadOptions{
+		EarlyHints: true,
+		GetRoutePreloads: func(r *http.Request) []string {
+			if r.URL.Path == "/" {
+				return []string{"images/hero.webp"}
+			}
+			return nil
+		},
+	})
+
+	server := httptest.NewServer(mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("{}"))
+			return
+		}
+		w.Write([]byte("<!doctype html><html></html>"))
+	})))
+	defer server.Close()
+
+	var earlyLinks []string
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				earlyLinks = header.Values("Link")
+			}
+			return nil
+		},
+	})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/", nil)
+	req.Header.Set("Accept", "text/html")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request error = %v", err)
+	}
+	resp.Body.Close()
+
+	links := strings.Join(resp.Header.Values("Link"), ", ")
+	for _, want := range []string{
+		"<" + env.config.GetStyleSheetURL() + ">; rel=preload; as=style; crossorigin=anonymous",
+		"<" + env.config.GetPublicFileMapURL() + ">; rel=modulepreload",
+		"<" + fontURL + ">; rel=preload; as=font; crossorigin=anonymous",
+		"<" + heroURL + ">; rel=preload; as=image",
+	} {
+		if !strings.Contains(links, want) {
+			t.Errorf("Link headers are missing %q:\n%s", want, links)
+		}
+	}
+	if strings.Contains(links, env.config.GetPublicURL("bg.png")) {
+		t.Errorf("Link headers preload a CSS background image:\n%s", links)
+	}
+	if len(earlyLinks) != len(resp.Header.Values("Link")) {
+		t.Errorf("103 Early Hints links = %v, want %v", earlyLinks, resp.Header.Values("Link"))
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/api", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request error = %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Values("Link"); len(got) != 0 {
+		t.Errorf("non-HTML response has Link headers: %v", got)
+	}
+}
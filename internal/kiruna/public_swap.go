@@ -0,0 +1,98 @@
+package ik
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type publicAssetSwap struct {
+	OriginalPath string `json:"originalPath"`
+	OldURL       string `json:"oldURL"`
+	NewURL       string `json:"newURL"`
+	HashedName   string `json:"hashedName"` // New value for the browser's public file map
+}
+
+// getIsSwappablePublicAsset reports whether references to a public file can
+// be patched in place in the browser. Scripts, stylesheets, documents and data
+// files are consumed in ways the refresh script can't see, so they aren't.
+func getIsSwappablePublicAsset(originalPath string) bool {
+	ext := strings.ToLower(path.Ext(originalPath))
+	if cssPreloadableExts[ext] {
+		return true
+	}
+	contentType := mime.TypeByExtension(ext)
+	for _, prefix := range []string{"image/", "font/", "audio/", "video/"} {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// getPublicFileOriginalPath returns the public file map key for a file in
+// PublicStaticDir, or an empty string if it isn't in there.
+func (c *Config) getPublicFileOriginalPath(fileName string) string {
+	absFile, err := filepath.Abs(fileName)
+	if err != nil {
+		return ""
+	}
+	absPublicDir, err := filepath.Abs(c.cleanSources.PublicStatic)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(absPublicDir, absFile)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// getPublicAssetSwap compares the public file maps from before and after a
+// rebuild, and returns what the browser needs to patch references to the
+// changed file in place. Returns nil if the page needs a full reload instead
+// (e.g., the file was added or removed, or isn't swappable).
+func (c *Config) getPublicAssetSwap(fileName string, oldFileMap, newFileMap FileMap) *publicAssetSwap {
+	originalPath := c.getPublicFileOriginalPath(fileName)
+	if originalPath == "" || !getIsSwappablePublicAsset(originalPath) {
+		return nil
+	}
+	oldVal, existedBefore := oldFileMap[originalPath]
+	newVal, existsNow := newFileMap[originalPath]
+	if !existedBefore || !existsNow {
+		return nil
+	}
+	base := c.getPublicURLBase()
+	return &publicAssetSwap{
+		OriginalPath: originalPath,
+		OldURL:       base + oldVal.Val,
+		NewURL:       base + newVal.Val,
+		HashedName:   newVal.Val,
+	}
+}
+
+func (c *Config) getPublicAssetSwapPayload(fileName string, oldFileMap FileMap) *refreshFilePayload {
+	if oldFileMap == nil {
+		return nil
+	}
+	newFileMap, err := c.loadMapFromGobIfExists(PublicFileMapGobName)
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error reading public file map: %v", err))
+		return nil
+	}
+	swap := c.getPublicAssetSwap(fileName, oldFileMap, newFileMap)
+	if swap == nil {
+		return nil
+	}
+	return &refreshFilePayload{
+		ChangeType:   changeTypePublicAsset,
+		PublicAssets: []publicAssetSwap{*swap},
+
+		// CSS may reference the asset via url(), and was rebuilt along with it
+		CriticalCSS:  base64.StdEncoding.EncodeToString([]byte(c.GetCriticalCSS())),
+		NormalCSSURL: c.GetStyleSheetURL(),
+	}
+}
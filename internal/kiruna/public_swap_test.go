@@ -0,0 +1,65 @@
+package ik
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGetIsSwappablePublicAsset(t *testing.T) {
+	for path, want := range map[string]bool{
+		"images/logo.png":   true,
+		"images/logo.SVG":   true,
+		"fonts/inter.woff2": true,
+		"media/intro.mp4":   true,
+		"scripts/app.js":    false,
+		"styles/extra.css":  false,
+		"robots.txt":        false,
+		"data.json":         false,
+	} {
+		if got := getIsSwappablePublicAsset(path); got != want {
+			t.Errorf("getIsSwappablePublicAsset(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestGetPublicAssetSwap(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	oldFileMap := FileMap{
+		"images/logo.png": {Val: "images/logo_kiruna_aaa.png"},
+		"scripts/app.js":  {Val: "scripts/app_kiruna_aaa.js"},
+	}
+	newFileMap := FileMap{
+		"images/logo.png": {Val: "images/logo_kiruna_bbb.png"},
+		"images/new.png":  {Val: "images/new_kiruna_bbb.png"},
+		"scripts/app.js":  {Val: "scripts/app_kiruna_bbb.js"},
+	}
+	publicDir := env.config.cleanSources.PublicStatic
+
+	swap := env.config.getPublicAssetSwap(filepath.Join(publicDir, "images/logo.png"), oldFileMap, newFileMap)
+	if swap == nil {
+		t.Fatalf("getPublicAssetSwap() = nil for a changed image")
+	}
+	base := env.config.getPublicURLBase()
+	want := publicAssetSwap{
+		OriginalPath: "images/logo.png",
+		OldURL:       base + "images/logo_kiruna_aaa.png",
+		NewURL:       base + "images/logo_kiruna_bbb.png",
+		HashedName:   "images/logo_kiruna_bbb.png",
+	}
+	if *swap != want {
+		t.Errorf("getPublicAssetSwap() = %+v, want %+v", *swap, want)
+	}
+
+	// Each of these needs a full reload instead
+	for _, fileName := range []string{
+		filepath.Join(publicDir, "images/new.png"),
+		filepath.Join(publicDir, "scripts/app.js"),
+		filepath.Join(testRootDir, "private-static/images/logo.png"),
+	} {
+		if swap := env.config.getPublicAssetSwap(fileName, oldFileMap, newFileMap); swap != nil {
+			t.Errorf("getPublicAssetSwap(%q) = %+v, want nil", fileName, *swap)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package ik
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"time"
@@ -12,20 +13,44 @@ const (
 )
 
 func (c *Config) waitForAppReadiness() bool {
-	for attempts := 0; attempts < maxReadinessAttempts; attempts++ {
-		url := fmt.Sprintf(
-			"http://localhost:%d%s",
-			MustGetPort(),
-			c.devConfig.HealthcheckEndpoint,
-		)
+	return waitForReadiness(c.getDevCtx(), fmt.Sprintf(
+		"http://localhost:%d%s",
+		c.getAppPortDev(),
+		c.devConfig.HealthcheckEndpoint,
+	))
+}
+
+// getDevCtx returns a context that is cancelled once the dev server starts
+// shutting down, so that nothing in the dev loop outlives DevServer.Stop.
+func (c *Config) getDevCtx() context.Context {
+	if c.devCtx == nil {
+		return context.Background()
+	}
+	return c.devCtx
+}
 
-		resp, err := http.Get(url)
-		if err == nil && resp.StatusCode == http.StatusOK {
-			return true
+// waitForReadiness polls url until it responds with a 200, with a linearly
+// increasing delay between attempts. It gives up early if ctx is cancelled.
+func waitForReadiness(ctx context.Context, url string) bool {
+	for attempts := 0; attempts < maxReadinessAttempts; attempts++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return false
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return true
+			}
 		}
 
 		delay := baseReadinessDelay + time.Duration(attempts)*baseReadinessDelay
-		time.Sleep(delay)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
 	}
 	return false
 }
@@ -0,0 +1,38 @@
+package ik
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWaitForReadiness(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if !waitForReadiness(context.Background(), server.URL) {
+		t.Errorf("waitForReadiness() = false for a ready server, want true")
+	}
+
+	// Grab a port that nothing listens on
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	unreachableURL := "http://" + ln.Addr().String()
+	ln.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if waitForReadiness(ctx, unreachableURL) {
+		t.Errorf("waitForReadiness() = true for an unreachable server, want false")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waitForReadiness() took %v after its context was cancelled", elapsed)
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -18,6 +22,22 @@ type clientManager struct {
 	register   chan *client
 	unregister chan *client
 	broadcast  chan refreshFilePayload
+	done       chan struct{} // closed once the manager has stopped
+
+	// Sent to every client as it connects, as they are usually
+	// produced right before a page reload
+	budgetWarningsMu sync.Mutex
+	budgetWarnings   []sizeBudgetViolation
+
+	// Together, these make up the build ID sent in every hello message, so
+	// that reconnecting clients can tell whether they missed anything
+	sessionID string
+	buildSeq  atomic.Uint64
+
+	// Set by the dev server before the manager is started
+	onClientError        func(clientErrorReport)
+	forwardConsoleErrors bool
+	isAllowedOrigin      func(origin string) bool
 }
 
 // Client represents a single WebSocket connection
@@ -30,20 +50,33 @@ type client struct {
 type Base64 = string
 
 type refreshFilePayload struct {
-	ChangeType   changeType `json:"changeType"`
-	CriticalCSS  Base64     `json:"criticalCSS"`
-	NormalCSSURL string     `json:"normalCSSURL"`
-	At           time.Time  `json:"at"`
+	ChangeType     changeType            `json:"changeType"`
+	CriticalCSS    Base64                `json:"criticalCSS"`
+	NormalCSSURL   string                `json:"normalCSSURL"`
+	BuildError     *buildErrorPayload    `json:"buildError,omitempty"`
+	BudgetWarnings []sizeBudgetViolation `json:"budgetWarnings,omitempty"`
+	PublicAssets   []publicAssetSwap     `json:"publicAssets,omitempty"`
+	HMRUpdates     []hmrUpdate           `json:"hmrUpdates,omitempty"`
+	BuildID        string                `json:"buildID,omitempty"`
+	At             time.Time             `json:"at"`
+
+	// Only sent in hello messages
+	ForwardConsoleErrors bool `json:"forwardConsoleErrors,omitempty"`
 }
 
 type changeType string
 
 const (
-	changeTypeNormalCSS   changeType = "normal"
-	changeTypeCriticalCSS changeType = "critical"
-	changeTypeOther       changeType = "other"
-	changeTypeRebuilding  changeType = "rebuilding"
-	changeTypeRevalidate  changeType = "revalidate"
+	changeTypeNormalCSS     changeType = "normal"
+	changeTypeCriticalCSS   changeType = "critical"
+	changeTypeOther         changeType = "other"
+	changeTypeRebuilding    changeType = "rebuilding"
+	changeTypeRevalidate    changeType = "revalidate"
+	changeTypeBuildError    changeType = "build-error"
+	changeTypeBudgetWarning changeType = "budget-warning"
+	changeTypePublicAsset   changeType = "public-asset"
+	changeTypeHMR           changeType = "hmr"
+	changeTypeHello         changeType = "hello"
 )
 
 func newClientManager() *clientManager {
@@ -52,13 +85,38 @@ func newClientManager() *clientManager {
 		register:   make(chan *client),
 		unregister: make(chan *client),
 		broadcast:  make(chan refreshFilePayload),
+		done:       make(chan struct{}),
+		sessionID:  strconv.FormatInt(time.Now().UnixNano(), 36),
 	}
 }
 
-// Start the manager to handle clients and broadcasting
-func (manager *clientManager) start() {
+func (manager *clientManager) getBuildID() string {
+	return manager.sessionID + "." + strconv.FormatUint(manager.buildSeq.Load(), 10)
+}
+
+// getChangesPage reports whether a payload of type ct leaves the page out of
+// date until it is applied (as opposed to just being informational).
+func getChangesPage(ct changeType) bool {
+	switch ct {
+	case changeTypeRebuilding, changeTypeBuildError, changeTypeBudgetWarning, changeTypeHello:
+		return false
+	}
+	return true
+}
+
+// Start the manager to handle clients and broadcasting.
+// Runs until stop is closed, at which point all clients are disconnected.
+func (manager *clientManager) start(stop <-chan struct{}) {
+	defer close(manager.done)
 	for {
 		select {
+		case <-stop:
+			for client := range manager.clients {
+				delete(manager.clients, client)
+				close(client.notify)
+				client.conn.Close()
+			}
+			return
 		case client := <-manager.register:
 			manager.clients[client] = true
 		case client := <-manager.unregister:
@@ -79,41 +137,118 @@ func (manager *clientManager) start() {
 	}
 }
 
-func (c *Config) mustReloadBroadcast(rfp refreshFilePayload) {
+// broadcastPayload sends rfp to all clients. It is a no-op once the manager has stopped.
+func (manager *clientManager) broadcastPayload(rfp refreshFilePayload) {
+	if getChangesPage(rfp.ChangeType) {
+		manager.buildSeq.Add(1)
+	}
+	rfp.BuildID = manager.getBuildID()
+	select {
+	case manager.broadcast <- rfp:
+	case <-manager.done:
+	}
+}
+
+func (manager *clientManager) registerClient(client *client) bool {
+	select {
+	case manager.register <- client:
+		return true
+	case <-manager.done:
+		return false
+	}
+}
+
+func (manager *clientManager) unregisterClient(client *client) {
+	select {
+	case manager.unregister <- client:
+	case <-manager.done:
+	}
+}
+
+func (manager *clientManager) setBudgetWarnings(violations []sizeBudgetViolation) {
+	manager.budgetWarningsMu.Lock()
+	defer manager.budgetWarningsMu.Unlock()
+	manager.budgetWarnings = violations
+}
+
+// getBudgetWarningsPayload returns nil if there is nothing to warn about.
+func (manager *clientManager) getBudgetWarningsPayload() *refreshFilePayload {
+	manager.budgetWarningsMu.Lock()
+	defer manager.budgetWarningsMu.Unlock()
+	if len(manager.budgetWarnings) == 0 {
+		return nil
+	}
+	return &refreshFilePayload{ChangeType: changeTypeBudgetWarning, BudgetWarnings: manager.budgetWarnings}
+}
+
+// reloadBroadcast waits for the app to become ready before telling the
+// browser to reload. It is a no-op if the dev server is shutting down.
+func (c *Config) reloadBroadcast(rfp refreshFilePayload) error {
 	if c.waitForAppReadiness() {
-		c.manager.broadcast <- rfp
-		return
+		c.manager.broadcastPayload(rfp)
+		return nil
 	}
-	errMsg := fmt.Sprintf("error: app never became ready: %v", rfp.ChangeType)
-	c.Logger.Error(errMsg)
-	panic(errMsg)
+	if c.getDevCtx().Err() != nil {
+		return nil
+	}
+	return fmt.Errorf("error: app never became ready: %v", rfp.ChangeType)
 }
 
 func (c *Config) GetRefreshScriptSha256Hash() string {
-	if !GetIsDev() {
+	if !c.getIsDev() {
 		return ""
 	}
-	hash := cryptoutil.Sha256Hash([]byte(GetRefreshScriptInner(getRefreshServerPort())))
+	hash := cryptoutil.Sha256Hash([]byte(c.getRefreshScriptInnerForMode()))
 	return bytesutil.ToBase64(hash)
 }
 
 func (c *Config) GetRefreshScript() template.HTML {
-	if !GetIsDev() {
+	if !c.getIsDev() || c.getDevProxyInjectsRefreshScript() {
 		return ""
 	}
 	result, _ := htmlutil.RenderElement(&htmlutil.Element{
 		Tag:       "script",
-		InnerHTML: template.HTML(GetRefreshScriptInner(getRefreshServerPort())),
+		InnerHTML: template.HTML(c.getRefreshScriptInnerForMode()),
+	})
+	return result
+}
+
+// GetRefreshScriptWithNonce is like GetRefreshScript, but renders a nonce
+// attribute instead of relying on a CSP hash.
+func (c *Config) GetRefreshScriptWithNonce(nonce string) template.HTML {
+	if nonce == "" || !c.getIsDev() || c.getDevProxyInjectsRefreshScript() {
+		return c.GetRefreshScript()
+	}
+	result, _ := htmlutil.RenderElement(&htmlutil.Element{
+		Tag:        "script",
+		Attributes: map[string]string{"nonce": nonce},
+		InnerHTML:  template.HTML(c.getRefreshScriptInnerForMode()),
 	})
 	return result
 }
 
 func GetRefreshScriptInner(port int) string {
-	return fmt.Sprintf(refreshScriptFmt, port)
+	return fmt.Sprintf(refreshScriptFmt, fmt.Sprintf(`"ws://localhost:%d/events"`, port))
+}
+
+// getRefreshScriptInnerSameOrigin returns a refresh script that connects
+// back to whatever origin served the page (i.e., the dev proxy).
+func getRefreshScriptInnerSameOrigin() string {
+	return fmt.Sprintf(
+		refreshScriptFmt,
+		`(location.protocol === "https:" ? "wss://" : "ws://") + location.host + "`+devProxyEventsPath+`"`,
+	)
+}
+
+func (c *Config) getRefreshScriptInnerForMode() string {
+	if c.getUseDevProxy() {
+		return getRefreshScriptInnerSameOrigin()
+	}
+	return GetRefreshScriptInner(c.getRefreshServerPort())
 }
 
-// changeTypes: "rebuilding", "other", "normal", "critical", "revalidate"
-// Element IDs: "__refreshscript-rebuilding", "__normal-css", "__critical-css"
+// changeTypes: "rebuilding", "other", "normal", "critical", "revalidate", "build-error", "budget-warning", "public-asset", "hmr", "hello"
+// Element IDs: "__refreshscript-rebuilding", "__refreshscript-build-error", "__refreshscript-disconnected", "__normal-css", "__critical-css"
 const refreshScriptFmt = `
 	function base64ToUTF8(base64) {
 		const bytes = Uint8Array.from(atob(base64), (m) => m.codePointAt(0) || 0);
@@ -130,10 +265,341 @@ const refreshScriptFmt = `
 		}, 150);
 	}
 
-	const ws = new WebSocket("ws://localhost:%d/events");
+	const wsURL = %s;
+	const reconnectBaseDelayMs = 250;
+	const reconnectMaxDelayMs = 5000;
+	let ws;
+	let knownBuildID = null;
+	let reconnectAttempts = 0;
+	let isUnloading = false;
+
+	const maxForwardedErrors = 100;
+	let forwardedErrors = 0;
+	const pendingErrorReports = [];
+	let isConsoleErrorPatched = false;
+
+	function removeRebuildingOverlay() {
+		const el = document.getElementById("__refreshscript-rebuilding");
+		if (el) el.remove();
+	}
+
+	function removeBuildErrorOverlay() {
+		const el = document.getElementById("__refreshscript-build-error");
+		if (el) el.remove();
+	}
+
+	function showBuildErrorOverlay(buildError) {
+		removeRebuildingOverlay();
+		removeBuildErrorOverlay();
+
+		const el = document.createElement("div");
+		el.id = "__refreshscript-build-error";
+		el.style.position = "fixed";
+		el.style.inset = "0";
+		el.style.zIndex = "1001";
+		el.style.overflow = "auto";
+		el.style.backgroundColor = "#111e";
+		el.style.color = "#eee";
+		el.style.fontFamily = "ui-monospace, SFMono-Regular, Menlo, monospace";
+		el.style.fontSize = "14px";
+		el.style.padding = "24px";
+
+		const closeBtn = document.createElement("button");
+		closeBtn.textContent = "Dismiss (Esc)";
+		closeBtn.style.float = "right";
+		closeBtn.style.cursor = "pointer";
+		closeBtn.onclick = removeBuildErrorOverlay;
+		el.appendChild(closeBtn);
+
+		const title = document.createElement("div");
+		title.textContent = "KIRUNA DEV: Build failed" +
+			(buildError.callback ? " (in callback " + buildError.callback + ")" : "");
+		title.style.color = "#ff6b6b";
+		title.style.fontSize = "18px";
+		title.style.fontWeight = "bold";
+		title.style.marginBottom = "16px";
+		el.appendChild(title);
+
+		function addPre(text, color) {
+			const pre = document.createElement("pre");
+			pre.textContent = text;
+			pre.style.whiteSpace = "pre-wrap";
+			pre.style.margin = "0 0 16px 0";
+			if (color) pre.style.color = color;
+			el.appendChild(pre);
+		}
+
+		for (const msg of buildError.esbuildMessages || []) {
+			const loc = msg.file ? msg.file + ":" + msg.line + ":" + (msg.column + 1) : "";
+			addPre(loc, "#9ecbff");
+			addPre(msg.text + (msg.lineText ? "\n\n    " + msg.lineText : ""));
+		}
+		if (buildError.compilerOutput) {
+			addPre(buildError.compilerOutput);
+		}
+		if (!buildError.compilerOutput && !(buildError.esbuildMessages || []).length) {
+			addPre(buildError.message);
+		}
+
+		document.body.appendChild(el);
+	}
+
+	function swapNormalCSS(normalCSSURL) {
+		const oldLink = document.getElementById("__normal-css");
+		const newLink = document.createElement("link");
+		newLink.id = "__normal-css";
+		newLink.rel = "stylesheet";
+		newLink.href = normalCSSURL;
+		newLink.onload = () => oldLink.remove();
+		oldLink.parentNode.insertBefore(newLink, oldLink.nextSibling);
+	}
+
+	function swapCriticalCSS(criticalCSS) {
+		const oldStyle = document.getElementById("__critical-css");
+		const newStyle = document.createElement("style");
+		newStyle.id = "__critical-css";
+		// Browsers hide the nonce attribute after parsing, so read the property
+		if (oldStyle && oldStyle.nonce) newStyle.nonce = oldStyle.nonce;
+		newStyle.innerHTML = base64ToUTF8(criticalCSS);
+		document.head.replaceChild(newStyle, oldStyle);
+	}
+
+	// Patches every reference to asset.oldURL in attributes and inline
+	// styles, so that scroll position and client state survive
+	function swapPublicAsset(asset) {
+		const swap = (s) => s.split(asset.oldURL).join(asset.newURL);
+		const attrs = ["src", "srcset", "href", "poster", "style"];
+		for (const el of document.querySelectorAll(attrs.map((a) => "[" + a + "]").join(","))) {
+			for (const attr of attrs) {
+				const val = el.getAttribute(attr);
+				if (val && val.includes(asset.oldURL)) el.setAttribute(attr, swap(val));
+			}
+		}
+		for (const el of document.querySelectorAll("style:not(#__critical-css)")) {
+			if (el.textContent.includes(asset.oldURL)) el.textContent = swap(el.textContent);
+		}
+		if (window.kiruna && window.kiruna.publicFileMap) {
+			window.kiruna.publicFileMap[asset.originalPath] = asset.hashedName;
+		}
+	}
+
+	function hardReload() {
+		const scrollY = window.scrollY;
+		if (scrollY > 0) {
+			localStorage.setItem(scrollYKey, scrollY);
+		}
+		window.location.reload();
+	}
+
+	// Backs import.meta.hot in bundled scripts, with one context per bundle URL
+	const hotContexts = new Map();
+	const pendingHotData = new Map();
+
+	window.__kirunaCreateHot = (url) => {
+		const ctx = {
+			data: pendingHotData.get(url) || {},
+			acceptCallbacks: [],
+			disposeCallbacks: [],
+			isAccepted: false,
+			accept(cb) {
+				ctx.isAccepted = true;
+				if (cb) ctx.acceptCallbacks.push(cb);
+			},
+			dispose(cb) {
+				ctx.disposeCallbacks.push(cb);
+			},
+			invalidate() {
+				hardReload();
+			},
+		};
+		pendingHotData.delete(url);
+		hotContexts.set(url, ctx);
+		return ctx;
+	};
+
+	// Resolves to false if the page needs a hard reload instead
+	async function applyHMRUpdate(update) {
+		const oldURL = new URL(update.oldURL, location.href).href;
+		const newURL = new URL(update.newURL, location.href).href;
+		const ctx = hotContexts.get(oldURL);
+		if (!ctx) {
+			// Fine to ignore if this page never loaded the bundle
+			return !document.querySelector('script[src="' + update.oldURL + '"]');
+		}
+		if (!ctx.isAccepted) return false;
+
+		const data = {};
+		for (const cb of ctx.disposeCallbacks) await cb(data);
+		hotContexts.delete(oldURL);
+		pendingHotData.set(newURL, data);
+
+		const newModule = await import(newURL);
+		for (const cb of ctx.acceptCallbacks) await cb(newModule);
+
+		if (window.kiruna && window.kiruna.publicFileMap) {
+			window.kiruna.publicFileMap[update.name + ".js"] = update.hashedName;
+		}
+		return true;
+	}
+
+	async function applyHMRUpdates(updates) {
+		for (const update of updates || []) {
+			if (!(await applyHMRUpdate(update))) return false;
+		}
+		return true;
+	}
+
+	window.addEventListener("keydown", (e) => {
+		if (e.key === "Escape") removeBuildErrorOverlay();
+	});
+
+	// Queued until the socket is open, so that errors during page load aren't lost
+	function forwardClientError(kind, message, stack, source, line, column) {
+		if (forwardedErrors >= maxForwardedErrors) return;
+		forwardedErrors++;
+		const report = JSON.stringify({
+			type: "client-error",
+			kind,
+			message: String(message),
+			stack: stack || "",
+			source: source || "",
+			line: line || 0,
+			column: column || 0,
+			pageURL: location.href,
+		});
+		if (ws && ws.readyState === WebSocket.OPEN) ws.send(report);
+		else pendingErrorReports.push(report);
+	}
+
+	function flushClientErrors() {
+		while (pendingErrorReports.length && ws.readyState === WebSocket.OPEN) {
+			ws.send(pendingErrorReports.shift());
+		}
+	}
+
+	function stringifyForReport(arg) {
+		if (arg instanceof Error) return arg.stack || String(arg);
+		if (typeof arg === "string") return arg;
+		try {
+			return JSON.stringify(arg);
+		} catch {
+			return String(arg);
+		}
+	}
+
+	window.addEventListener("error", (e) => {
+		if (!e.message) return;
+		forwardClientError("error", e.message, e.error && e.error.stack, e.filename, e.lineno, e.colno);
+	});
+
+	window.addEventListener("unhandledrejection", (e) => {
+		const reason = e.reason;
+		const message = reason instanceof Error ? reason.message : stringifyForReport(reason);
+		forwardClientError("unhandledrejection", message, reason && reason.stack);
+	});
+
+	function patchConsoleError() {
+		if (isConsoleErrorPatched) return;
+		isConsoleErrorPatched = true;
+		const originalConsoleError = console.error;
+		console.error = (...args) => {
+			originalConsoleError.apply(console, args);
+			// Skip the refresh script's own messages
+			if (typeof args[0] === "string" && args[0].startsWith("KIRUNA DEV:")) return;
+			forwardClientError("console.error", args.map(stringifyForReport).join(" "));
+		};
+	}
 
-	ws.onmessage = (e) => {
-		const { changeType, criticalCSS, normalCSSURL, at } = JSON.parse(e.data);
+	function showDisconnectedBadge() {
+		if (document.getElementById("__refreshscript-disconnected")) return;
+		const el = document.createElement("div");
+		el.id = "__refreshscript-disconnected";
+		el.textContent = "KIRUNA DEV: Disconnected, reconnecting...";
+		el.style.position = "fixed";
+		el.style.bottom = "12px";
+		el.style.right = "12px";
+		el.style.zIndex = "1002";
+		el.style.padding = "6px 10px";
+		el.style.borderRadius = "4px";
+		el.style.backgroundColor = "#333e";
+		el.style.color = "#eee";
+		el.style.fontFamily = "ui-monospace, SFMono-Regular, Menlo, monospace";
+		el.style.fontSize = "12px";
+		el.style.pointerEvents = "none";
+		(document.body || document.documentElement).appendChild(el);
+	}
+
+	function removeDisconnectedBadge() {
+		const el = document.getElementById("__refreshscript-disconnected");
+		if (el) el.remove();
+	}
+
+	function scheduleReconnect() {
+		const delay = Math.min(reconnectBaseDelayMs * 2 ** reconnectAttempts, reconnectMaxDelayMs);
+		reconnectAttempts++;
+		setTimeout(connect, delay);
+	}
+
+	function connect() {
+		ws = new WebSocket(wsURL);
+		ws.onmessage = onMessage;
+
+		ws.onclose = () => {
+			if (isUnloading) return;
+			if (reconnectAttempts == 0) console.log("KIRUNA DEV: WebSocket closed, reconnecting...");
+			showDisconnectedBadge();
+			scheduleReconnect();
+		};
+
+		// Always followed by a close event, which handles reconnecting
+		ws.onerror = () => {};
+	}
+
+	function onMessage(e) {
+		const {
+			changeType,
+			criticalCSS,
+			normalCSSURL,
+			buildError,
+			budgetWarnings,
+			publicAssets,
+			hmrUpdates,
+			buildID,
+			forwardConsoleErrors,
+			at,
+		} = JSON.parse(e.data);
+
+		if (changeType == "hello") {
+			reconnectAttempts = 0;
+			removeDisconnectedBadge();
+			if (forwardConsoleErrors) patchConsoleError();
+			flushClientErrors();
+			if (knownBuildID !== null && knownBuildID !== buildID) {
+				console.log("KIRUNA DEV: Reconnected to a new build, reloading");
+				hardReload();
+				return;
+			}
+			knownBuildID = buildID;
+			return;
+		}
+
+		if (buildID) knownBuildID = buildID;
+
+		if (changeType == "budget-warning") {
+			console.warn("KIRUNA DEV: Size budgets exceeded");
+			console.table(budgetWarnings);
+			return;
+		}
+
+		if (changeType == "build-error") {
+			console.error("KIRUNA DEV: Build failed", buildError);
+			showBuildErrorOverlay(buildError);
+			return;
+		}
+
+		if (changeType != "rebuilding") {
+			removeBuildErrorOverlay();
+		}
 
 		if (changeType == "rebuilding") {
 			console.log("KIRUNA DEV: Rebuilding server...");
@@ -163,29 +629,47 @@ const refreshScriptFmt = `
 		}
 
 		if (changeType == "other") {
-			const scrollY = window.scrollY;
-			if (scrollY > 0) {
-				localStorage.setItem(scrollYKey, scrollY);
-			}
-			window.location.reload();
+			hardReload();
+		}
+
+		if (changeType == "hmr") {
+			console.log("KIRUNA DEV: Applying module updates", hmrUpdates);
+			applyHMRUpdates(hmrUpdates).then(
+				(ok) => {
+					if (!ok) {
+						console.log("KIRUNA DEV: Module update not accepted, reloading");
+						hardReload();
+					}
+				},
+				(err) => {
+					console.error("KIRUNA DEV: Module update failed, reloading", err);
+					hardReload();
+				},
+			);
 		}
 
 		if (changeType == "normal") {
-			const oldLink = document.getElementById("__normal-css");
-			const newLink = document.createElement("link");
-			newLink.id = "__normal-css";
-			newLink.rel = "stylesheet";
-			newLink.href = normalCSSURL;
-			newLink.onload = () => oldLink.remove();
-			oldLink.parentNode.insertBefore(newLink, oldLink.nextSibling);
+			swapNormalCSS(normalCSSURL);
 		}
 
 		if (changeType == "critical") {
+			swapCriticalCSS(criticalCSS);
+		}
+
+		if (changeType == "public-asset") {
+			console.log("KIRUNA DEV: Swapping public assets", publicAssets);
+			for (const asset of publicAssets || []) {
+				swapPublicAsset(asset);
+			}
+			const oldLink = document.getElementById("__normal-css");
+			if (oldLink && normalCSSURL && oldLink.getAttribute("href") !== normalCSSURL) {
+				swapNormalCSS(normalCSSURL);
+			}
 			const oldStyle = document.getElementById("__critical-css");
-			const newStyle = document.createElement("style");
-			newStyle.id = "__critical-css";
-			newStyle.innerHTML = base64ToUTF8(criticalCSS);
-			document.head.replaceChild(newStyle, oldStyle);
+			if (oldStyle && criticalCSS && oldStyle.innerHTML !== base64ToUTF8(criticalCSS)) {
+				swapCriticalCSS(criticalCSS);
+			}
+			removeRebuildingOverlay();
 		}
 			
 		if (changeType == "revalidate") {
@@ -201,34 +685,59 @@ const refreshScriptFmt = `
 				if (el) el.remove();
 			}
 		}
-	};
-
-	ws.onclose = () => {
-		console.log("KIRUNA DEV: WebSocket closed");
-		window.location.reload();
-	};
-
-	ws.onerror = (e) => {
-		console.log("KIRUNA DEV: WebSocket error", e);
-		ws.close();
-		window.location.reload();
-	};
+	}
 
 	window.addEventListener("beforeunload", () => {
-		ws.onclose = () => {};
+		isUnloading = true;
 		ws.close();
 	});
+
+	connect();
 `
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 	CheckOrigin: func(r *http.Request) bool {
-		// Allow all connections
+		// Allow all connections, as receiving reloads is harmless. Anything
+		// a client sends back is subject to isLocalDevOrigin.
 		return true
 	},
 }
 
+// isLocalDevOrigin reports whether a WebSocket request comes from a page
+// served by the app or the dev proxy on localhost. Requests without an
+// Origin don't come from a browser (e.g., the app's own connection), and
+// are allowed.
+func (c *Config) isLocalDevOrigin(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+	default:
+		return false
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return false
+	}
+	return port == c.port || (c.appPortBehindProxy != 0 && port == c.appPortBehindProxy)
+}
+
+// originAllowed applies isAllowed, which is nil until the dev server sets
+// it, in which case only requests without an Origin are allowed.
+func originAllowed(isAllowed func(string) bool, origin string) bool {
+	if isAllowed == nil {
+		return origin == ""
+	}
+	return isAllowed(origin)
+}
+
 func websocketHandler(manager *clientManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		conn, err := upgrader.Upgrade(w, r, nil)
@@ -239,20 +748,43 @@ func websocketHandler(manager *clientManager) http.HandlerFunc {
 
 		msg := make(chan refreshFilePayload, 1)
 		client := &client{id: r.RemoteAddr, conn: conn, notify: msg}
-		manager.register <- client
+		if !manager.registerClient(client) {
+			conn.Close()
+			return
+		}
 
-		defer func() {
-			manager.unregister <- client
-		}()
+		defer manager.unregisterClient(client)
+
+		hello := refreshFilePayload{
+			ChangeType:           changeTypeHello,
+			BuildID:              manager.getBuildID(),
+			ForwardConsoleErrors: manager.forwardConsoleErrors,
+		}
+		if err := conn.WriteJSON(hello); err != nil {
+			return
+		}
 
-		// Read routine to handle client messages
+		if rfp := manager.getBudgetWarningsPayload(); rfp != nil {
+			if err := conn.WriteJSON(rfp); err != nil {
+				return
+			}
+		}
+
+		// Read routine to handle client messages. Messages from other
+		// origins are still read (to notice disconnects) but ignored.
+		acceptMessages := originAllowed(manager.isAllowedOrigin, r.Header.Get("Origin"))
+		conn.SetReadLimit(clientMessageReadLimit)
 		go func() {
 			defer conn.Close()
 			for {
-				if _, _, err := conn.ReadMessage(); err != nil {
-					manager.unregister <- client
+				_, data, err := conn.ReadMessage()
+				if err != nil {
+					manager.unregisterClient(client)
 					break
 				}
+				if acceptMessages {
+					manager.handleClientMessage(data)
+				}
 			}
 		}()
 
@@ -0,0 +1,237 @@
+package ik
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestRefreshHelloBuildID(t *testing.T) {
+	manager := newClientManager()
+	stop := make(chan struct{})
+	go manager.start(stop)
+	defer close(stop)
+
+	server := httptest.NewServer(websocketHandler(manager))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	readHello := func() (*websocket.Conn, string) {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var rfp refreshFilePayload
+		if err := conn.ReadJSON(&rfp); err != nil {
+			t.Fatalf("ReadJSON() error = %v", err)
+		}
+		if rfp.ChangeType != changeTypeHello || rfp.BuildID == "" {
+			t.Fatalf("first message = %+v, want hello with a build ID", rfp)
+		}
+		return conn, rfp.BuildID
+	}
+
+	conn, firstID := readHello()
+	defer conn.Close()
+
+	readBroadcast := func(rfp refreshFilePayload) string {
+		t.Helper()
+		manager.broadcastPayload(rfp)
+		var got refreshFilePayload
+		if err := conn.ReadJSON(&got); err != nil {
+			t.Fatalf("ReadJSON() error = %v", err)
+		}
+		return got.BuildID
+	}
+
+	// Informational payloads don't change the build ID
+	if id := readBroadcast(refreshFilePayload{ChangeType: changeTypeRebuilding}); id != firstID {
+		t.Errorf("build ID after rebuilding = %q, want %q", id, firstID)
+	}
+
+	newID := readBroadcast(refreshFilePayload{ChangeType: changeTypeOther})
+	if newID == firstID {
+		t.Errorf("build ID did not change after a reload broadcast")
+	}
+
+	// A reconnecting client learns about the new build from its hello
+	conn2, helloID := readHello()
+	defer conn2.Close()
+	if helloID != newID {
+		t.Errorf("hello build ID = %q, want %q", helloID, newID)
+	}
+
+	// A restarted dev server gets a new session, and thus a new build ID
+	if id := newClientManager().getBuildID(); id == newID || id == firstID {
+		t.Errorf("new session build ID = %q, want a fresh ID", id)
+	}
+}
+
+func TestRefreshClientErrorForwarding(t *testing.T) {
+	manager := newClientManager()
+	manager.forwardConsoleErrors = true
+	reports := make(chan clientErrorReport, 1)
+	manager.onClientError = func(report clientErrorReport) { reports <- report }
+	stop := make(chan struct{})
+	go manager.start(stop)
+	defer close(stop)
+
+	server := httptest.NewServer(websocketHandler(manager))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	var hello refreshFilePayload
+	if err := conn.ReadJSON(&hello); err != nil || !hello.ForwardConsoleErrors {
+		t.Fatalf("hello = %+v (error = %v), want ForwardConsoleErrors", hello, err)
+	}
+
+	// Unrecognized messages are ignored
+	conn.WriteMessage(websocket.TextMessage, []byte("not json"))
+	conn.WriteJSON(map[string]string{"type": "something-else"})
+
+	want := clientErrorReport{
+		Type:    clientMessageTypeError,
+		Kind:    "error",
+		Message: "boom is not defined",
+		Source:  "http://localhost:8080/public/main_kiruna_abc.js",
+		Line:    3,
+		Column:  7,
+		PageURL: "http://localhost:8080/",
+	}
+	if err := conn.WriteJSON(want); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	select {
+	case got := <-reports:
+		if got != want {
+			t.Errorf("reported %+v, want %+v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("client error was never reported")
+	}
+}
+
+func TestRefreshClientMessagesRequireLocalOrigin(t *testing.T) {
+	server := httptest.NewUnstartedServer(nil)
+	c := &Config{}
+	c.port = server.Listener.Addr().(*net.TCPAddr).Port
+
+	manager := newClientManager()
+	manager.isAllowedOrigin = c.isLocalDevOrigin
+	reports := make(chan clientErrorReport, 2)
+	manager.onClientError = func(report clientErrorReport) { reports <- report }
+	stop := make(chan struct{})
+	go manager.start(stop)
+	defer close(stop)
+
+	server.Config.Handler = websocketHandler(manager)
+	server.Start()
+	defer server.Close()
+
+	send := func(origin, message string) {
+		header := http.Header{"Origin": {origin}}
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), header)
+		if err != nil {
+			t.Fatalf("Dial() with origin %q error = %v", origin, err)
+		}
+		defer conn.Close()
+		var hello refreshFilePayload
+		if err := conn.ReadJSON(&hello); err != nil {
+			t.Fatalf("ReadJSON() error = %v", err)
+		}
+		if err := conn.WriteJSON(clientErrorReport{Type: clientMessageTypeError, Message: message}); err != nil {
+			t.Fatalf("WriteJSON() error = %v", err)
+		}
+	}
+
+	send("http://evil.example", "from elsewhere")
+	send("http://localhost:"+strconv.Itoa(c.port), "from the app")
+
+	select {
+	case got := <-reports:
+		if got.Message != "from the app" {
+			t.Errorf("reported %q, want only the local origin's message", got.Message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("client error was never reported")
+	}
+	select {
+	case got := <-reports:
+		t.Errorf("unexpected second report %q", got.Message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestIsLocalDevOrigin(t *testing.T) {
+	c := &Config{}
+	c.port = 8080
+	c.appPortBehindProxy = 8081
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://localhost:8080", true},
+		{"http://127.0.0.1:8081", true},
+		{"http://[::1]:8080", true},
+		{"http://localhost:9999", false},
+		{"http://localhost", false},
+		{"http://evil.example:8080", false},
+		{"http://localhost.evil.example:8080", false},
+		{"file://localhost:8080", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		if got := c.isLocalDevOrigin(tt.origin); got != tt.want {
+			t.Errorf("isLocalDevOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestAppEventsRejectsForeignOrigin(t *testing.T) {
+	c := &Config{}
+	hub := newAppEventsHub()
+	hub.isAllowedOrigin = c.isLocalDevOrigin
+	server := httptest.NewServer(http.HandlerFunc(hub.handler))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {"http://evil.example"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Dial() from foreign origin = %v, want %d", err, http.StatusForbidden)
+	}
+
+	// The app itself connects without an Origin
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Dial() without origin error = %v", err)
+	}
+	conn.Close()
+}
+
+func TestSanitizeClientError(t *testing.T) {
+	if got, want := sanitizeClientErrorField("boom\nlevel=INFO msg=forged\x1b[2J\r"), "boomlevel=INFO msg=forged[2J"; got != want {
+		t.Errorf("sanitizeClientErrorField() = %q, want %q", got, want)
+	}
+
+	stack := "Error: boom\n    at main.js:1:1\r\n\nlevel=INFO msg=forged\x1b]0;title\x07"
+	want := "    Error: boom\n    at main.js:1:1\n    level=INFO msg=forged]0;title"
+	if got := sanitizeClientErrorStack(stack); got != want {
+		t.Errorf("sanitizeClientErrorStack() = %q, want %q", got, want)
+	}
+}
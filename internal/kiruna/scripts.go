@@ -0,0 +1,253 @@
+package ik
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	esbuild "github.com/evanw/esbuild/pkg/api"
+	"github.com/sjc5/kit/pkg/htmlutil"
+)
+
+// scriptFileMapKey returns the public file map key under which
+// the bundled output for the named script entry is recorded.
+func scriptFileMapKey(name string) string {
+	return name + ".js"
+}
+
+func (c *Config) getScriptEntryNames() []string {
+	names := make([]string, 0, len(c.cleanSources.ScriptEntries))
+	for name := range c.cleanSources.ScriptEntries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *Config) processScripts() error {
+	outputs, err := c.buildScripts()
+	if err != nil {
+		return err
+	}
+	return c.commitScriptsToPublicFileMap(outputs)
+}
+
+// buildScripts bundles every script entry and writes the content-hashed
+// outputs to the public dist dir. It returns a map of entry names to file
+// map values. The public file map is not touched here (see commitScriptsToPublicFileMap).
+func (c *Config) buildScripts() (map[string]fileVal, error) {
+	if len(c.cleanSources.ScriptEntries) == 0 {
+		return nil, nil
+	}
+
+	isDev := c.getIsDev()
+
+	outputPath := c.__dist.S().Kiruna.S().Static.S().Public.FullPath()
+
+	names := c.getScriptEntryNames()
+
+	entryPoints := make([]esbuild.EntryPoint, 0, len(names))
+	for _, name := range names {
+		entryPoints = append(entryPoints, esbuild.EntryPoint{
+			InputPath:  c.cleanSources.ScriptEntries[name],
+			OutputPath: name,
+		})
+	}
+
+	sourcemap := esbuild.SourceMapNone
+	if isDev {
+		sourcemap = esbuild.SourceMapInline
+	}
+
+	ctx, ctxErr := esbuild.Context(esbuild.BuildOptions{
+		EntryPointsAdvanced: entryPoints,
+		Bundle:              true,
+		Format:              esbuild.FormatESModule,
+		MinifyWhitespace:    !isDev,
+		MinifyIdentifiers:   !isDev,
+		MinifySyntax:        !isDev,
+		Sourcemap:           sourcemap,
+		Define:              getHMRDefine(isDev),
+		Banner:              getHMRBanner(isDev),
+		Outdir:              outputPath,
+		Write:               false,
+		Metafile:            true,
+	})
+	if ctxErr != nil {
+		return nil, fmt.Errorf("error creating esbuild context: %w", &esbuildError{messages: ctxErr.Errors})
+	}
+
+	c.esbuildCtxScripts.mu.Lock()
+	c.esbuildCtxScripts.ctx = ctx
+	c.esbuildCtxScripts.mu.Unlock()
+
+	result := ctx.Rebuild()
+	if err := collectEsbuildErrors(result); err != nil {
+		return nil, fmt.Errorf("error building scripts: %w", err)
+	}
+
+	c.recordScriptWarnings(result.Warnings)
+
+	var metafile Metafile
+	if err := json.Unmarshal([]byte(result.Metafile), &metafile); err != nil {
+		return nil, fmt.Errorf("error unmarshalling esbuild metafile: %v", err)
+	}
+
+	c.scriptReliedUponFilesMu.Lock()
+	c.scriptReliedUponFiles = map[string]struct{}{}
+	for path := range metafile.Inputs {
+		c.scriptReliedUponFiles[filepath.Clean(path)] = struct{}{}
+	}
+	c.scriptReliedUponFilesMu.Unlock()
+
+	if err := os.MkdirAll(outputPath, 0755); err != nil {
+		return nil, fmt.Errorf("error creating output directory: %v", err)
+	}
+
+	outputs := make(map[string]fileVal, len(names))
+
+	for _, outputFile := range result.OutputFiles {
+		baseName := filepath.Base(outputFile.Path)
+		if filepath.Ext(baseName) != ".js" {
+			return nil, fmt.Errorf(
+				"script bundle emitted unsupported non-JS output (%s); import CSS through NormalCSSEntry instead",
+				baseName,
+			)
+		}
+
+		name := strings.TrimSuffix(baseName, ".js")
+		outputFileName := getHashedFilenameFromBytes(outputFile.Contents, baseName)
+
+		if err := os.WriteFile(filepath.Join(outputPath, outputFileName), outputFile.Contents, 0644); err != nil {
+			return nil, fmt.Errorf("error writing script output: %v", err)
+		}
+
+		outputs[name] = fileVal{Val: outputFileName, Integrity: getSRIDigest(outputFile.Contents)}
+	}
+
+	return outputs, nil
+}
+
+// commitScriptsToPublicFileMap records the given script outputs in the
+// public file map, removes any stale outputs from a prior build, and
+// persists the new outputs to the scripts ref file so that subsequent
+// granular public file builds can carry them forward.
+func (c *Config) commitScriptsToPublicFileMap(outputs map[string]fileVal) error {
+	if len(c.cleanSources.ScriptEntries) == 0 {
+		return nil
+	}
+
+	oldOutputs, err := c.loadScriptsFileRef()
+	if err != nil {
+		return err
+	}
+
+	publicDistDir := c.__dist.S().Kiruna.S().Static.S().Public.FullPath()
+
+	for name, oldOutput := range oldOutputs {
+		if outputs[name].Val == oldOutput.Val {
+			continue
+		}
+		err := os.Remove(filepath.Join(publicDistDir, oldOutput.Val))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing old script output: %v", err)
+		}
+	}
+
+	refBytes, err := json.Marshal(outputs)
+	if err != nil {
+		return fmt.Errorf("error marshalling scripts file ref: %v", err)
+	}
+	refPath := c.__dist.S().Kiruna.S().Internal.S().ScriptsFileRefDotJSON.FullPath()
+	if err := os.WriteFile(refPath, refBytes, 0644); err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+
+	fileMap, err := c.getInitialPublicFileMapFromGobBuildtime()
+	if err != nil {
+		return fmt.Errorf("error reading public file map: %v", err)
+	}
+
+	for name := range oldOutputs {
+		delete(fileMap, scriptFileMapKey(name))
+	}
+	if err := mergeScriptsIntoFileMap(fileMap, outputs); err != nil {
+		return err
+	}
+
+	return c.savePublicFileMap(fileMap)
+}
+
+func (c *Config) loadScriptsFileRef() (map[string]fileVal, error) {
+	refPath := c.__dist.S().Kiruna.S().Internal.S().ScriptsFileRefDotJSON.FullPath()
+
+	content, err := os.ReadFile(refPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return map[string]fileVal{}, nil
+		}
+		return nil, fmt.Errorf("error reading scripts file ref: %v", err)
+	}
+
+	var outputs map[string]fileVal
+	if err := json.Unmarshal(content, &outputs); err != nil {
+		return nil, fmt.Errorf("error unmarshalling scripts file ref: %v", err)
+	}
+	return outputs, nil
+}
+
+func mergeScriptsIntoFileMap(fileMap FileMap, outputs map[string]fileVal) error {
+	for name, output := range outputs {
+		key := scriptFileMapKey(name)
+		if _, exists := fileMap[key]; exists {
+			return newScriptCollisionError(name)
+		}
+		fileMap[key] = output
+	}
+	return nil
+}
+
+func newScriptCollisionError(name string) error {
+	return fmt.Errorf(
+		"script entry %q collides with public static file %q; rename one of them", name, scriptFileMapKey(name),
+	)
+}
+
+func (c *Config) GetScriptURL(name string) string {
+	if _, ok := c.cleanSources.ScriptEntries[name]; !ok {
+		c.Logger.Error(fmt.Sprintf("GetScriptURL: no script entry named %s", name))
+		return ""
+	}
+	return c.GetPublicURL(scriptFileMapKey(name))
+}
+
+func (c *Config) getInitialScriptElements() (template.HTML, error) {
+	names := c.getScriptEntryNames()
+
+	var htmlBuilder strings.Builder
+
+	for _, name := range names {
+		attrs := map[string]string{"type": "module", "src": c.GetScriptURL(name)}
+		if integrity := c.GetPublicAssetIntegrity(scriptFileMapKey(name)); integrity != "" {
+			attrs["integrity"] = integrity
+			attrs["crossorigin"] = "anonymous"
+		}
+		scriptEl := htmlutil.Element{Tag: "script", Attributes: attrs}
+		if err := htmlutil.RenderElementToBuilder(&scriptEl, &htmlBuilder); err != nil {
+			return "", fmt.Errorf("error rendering element to builder: %v", err)
+		}
+	}
+
+	return template.HTML(htmlBuilder.String()), nil
+}
+
+func (c *Config) GetScriptElements() template.HTML {
+	result, _ := c.runtimeCache.scriptElements.Get()
+	return result
+}
@@ -0,0 +1,98 @@
+package ik
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildScripts(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "client/dep.ts", "export const greeting: string = 'hello';")
+	env.createTestFile(t, "client/main.ts", "import { greeting } from './dep';\nconsole.log(greeting);")
+	env.createTestFile(t, "public-static/favicon.ico", "icon")
+
+	env.config.cleanSources.ScriptEntries = map[string]string{
+		"main": filepath.Join(testRootDir, "client/main.ts"),
+	}
+
+	if err := env.config.SetupDistDir(); err != nil {
+		t.Fatalf("SetupDistDir() error = %v", err)
+	}
+	if err := env.config.handlePublicFiles(false); err != nil {
+		t.Fatalf("handlePublicFiles() error = %v", err)
+	}
+	if err := env.config.processScripts(); err != nil {
+		t.Fatalf("processScripts() error = %v", err)
+	}
+
+	fileMap, err := env.config.getInitialPublicFileMapFromGobBuildtime()
+	if err != nil {
+		t.Fatalf("Failed to load public file map: %v", err)
+	}
+
+	if _, ok := fileMap["favicon.ico"]; !ok {
+		t.Errorf("Public file map is missing favicon.ico")
+	}
+
+	entry, ok := fileMap["main.js"]
+	if !ok {
+		t.Fatalf("Public file map is missing main.js")
+	}
+	if !strings.HasPrefix(entry.Val, "main_") || !strings.HasSuffix(entry.Val, ".js") {
+		t.Errorf("Invalid hashed script filename: %v", entry.Val)
+	}
+
+	content, err := os.ReadFile(filepath.Join(testRootDir, "dist/kiruna/static/public", entry.Val))
+	if err != nil {
+		t.Fatalf("Failed to read script output: %v", err)
+	}
+	if !strings.Contains(string(content), "hello") {
+		t.Errorf("Script output does not contain bundled dependency: %s", content)
+	}
+
+	// Re-processing public files must carry the script entry forward
+	if err := env.config.handlePublicFiles(true); err != nil {
+		t.Fatalf("handlePublicFiles() error = %v", err)
+	}
+	fileMap, err = env.config.getInitialPublicFileMapFromGobBuildtime()
+	if err != nil {
+		t.Fatalf("Failed to load public file map: %v", err)
+	}
+	if fileMap["main.js"] != entry {
+		t.Errorf("Script entry after granular public build = %v, want: %v", fileMap["main.js"], entry)
+	}
+
+	if got := env.config.GetScriptURL("main"); got != "/public/"+entry.Val {
+		t.Errorf("GetScriptURL() = %v, want: %v", got, "/public/"+entry.Val)
+	}
+
+	if _, ok := env.config.scriptReliedUponFiles[filepath.Join(testRootDir, "client/dep.ts")]; !ok {
+		t.Errorf("Script import graph is missing client/dep.ts: %v", env.config.scriptReliedUponFiles)
+	}
+}
+
+func TestBuildScriptsCollision(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "client/main.ts", "console.log(1);")
+	env.createTestFile(t, "public-static/main.js", "console.log(2);")
+
+	env.config.cleanSources.ScriptEntries = map[string]string{
+		"main": filepath.Join(testRootDir, "client/main.ts"),
+	}
+
+	if err := env.config.SetupDistDir(); err != nil {
+		t.Fatalf("SetupDistDir() error = %v", err)
+	}
+	if err := env.config.handlePublicFiles(false); err != nil {
+		t.Fatalf("handlePublicFiles() error = %v", err)
+	}
+	if err := env.config.processScripts(); err == nil {
+		t.Errorf("processScripts() error = nil, want collision error")
+	}
+}
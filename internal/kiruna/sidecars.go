@@ -0,0 +1,189 @@
+package ik
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sjc5/kit/pkg/grace"
+)
+
+// DevSidecar is a long-running companion process (e.g., a CSS watcher, a
+// mock API, or a queue emulator) that the dev server starts before your app,
+// restarts if it crashes, and stops on shutdown.
+type DevSidecar struct {
+	Name    string   // Required. Must be unique. Used as the prefix of its output.
+	Command string   // Required. Looked up in PATH if it contains no slashes.
+	Args    []string //
+	Dir     string   // Working directory. Defaults to the dev server's.
+	Env     []string // Extra "KEY=VALUE" entries, added on top of the inherited environment
+
+	// If true, the sidecar is not restarted when it exits.
+	NoRestart bool
+}
+
+const (
+	sidecarStopTimeout      = 5 * time.Second
+	sidecarBaseRestartDelay = 500 * time.Millisecond
+	sidecarMaxRestartDelay  = 30 * time.Second
+
+	// A sidecar that ran at least this long before exiting is restarted
+	// without delay accumulated from earlier crashes
+	sidecarStableAfter = 10 * time.Second
+)
+
+type sidecarProcess struct {
+	cmd      *exec.Cmd
+	done     chan struct{} // Closed once the process has exited
+	stopping atomic.Bool
+}
+
+func validateSidecars(sidecars []DevSidecar) error {
+	seen := make(map[string]bool, len(sidecars))
+	for _, sc := range sidecars {
+		if sc.Name == "" || sc.Command == "" {
+			return fmt.Errorf("error: sidecar (%q) in kiruna.DevConfig.Sidecars needs both a Name and a Command", sc.Name)
+		}
+		if seen[sc.Name] {
+			return fmt.Errorf("error: duplicate sidecar name (%q) in kiruna.DevConfig.Sidecars", sc.Name)
+		}
+		seen[sc.Name] = true
+		for _, kv := range sc.Env {
+			if !strings.Contains(kv, "=") {
+				return fmt.Errorf("error: invalid env entry (%q) for sidecar %q. Must be in KEY=VALUE form.", kv, sc.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func getSidecarRestartDelay(failures int) time.Duration {
+	delay := sidecarBaseRestartDelay
+	for i := 0; i < failures && delay < sidecarMaxRestartDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, sidecarMaxRestartDelay)
+}
+
+func (c *Config) startSidecarsDev() {
+	c.sidecars.mu.Lock()
+	c.sidecars.v = make(map[string]*sidecarProcess, len(c.devConfig.Sidecars))
+	c.sidecarRestarts = make(map[string]*time.Timer)
+	c.sidecarsGen++
+	gen := c.sidecarsGen
+	c.sidecars.mu.Unlock()
+
+	for i := range c.devConfig.Sidecars {
+		c.startSidecarDev(&c.devConfig.Sidecars[i], 0, gen)
+	}
+}
+
+// startSidecarDev is a no-op once the run it belongs to (gen) has been
+// stopped by stopSidecarsDev, even if a new run has started since.
+// failures counts the crashes since the sidecar last ran stably.
+func (c *Config) startSidecarDev(sc *DevSidecar, failures int, gen uint64) {
+	c.sidecars.mu.Lock()
+	defer c.sidecars.mu.Unlock()
+
+	if c.sidecars.v == nil || c.sidecarsGen != gen {
+		return
+	}
+	delete(c.sidecarRestarts, sc.Name)
+
+	prefix := "[" + sc.Name + "] "
+	stdout, stderr := newPrefixWriter(os.Stdout, prefix), newPrefixWriter(os.Stderr, prefix)
+	p := &sidecarProcess{cmd: exec.Command(sc.Command, sc.Args...), done: make(chan struct{})}
+	p.cmd.Dir = sc.Dir
+	p.cmd.Env = append(append(getPrefixedOutputEnv(), os.Environ()...), sc.Env...)
+	p.cmd.Stdout = stdout
+	p.cmd.Stderr = stderr
+
+	if err := p.cmd.Start(); err != nil {
+		c.Logger.Error(fmt.Sprintf("error: failed to start sidecar %s: %v", sc.Name, err))
+		if !sc.NoRestart {
+			c.scheduleSidecarRestartLocked(sc, failures, gen, getSidecarRestartDelay(failures))
+		}
+		return
+	}
+
+	c.sidecars.v[sc.Name] = p
+	c.Logger.Info("Sidecar is running", "name", sc.Name, "pid", p.cmd.Process.Pid)
+
+	startedAt := time.Now()
+	go func() {
+		err := p.cmd.Wait()
+		stdout.flush()
+		stderr.flush()
+		close(p.done)
+
+		if p.stopping.Load() {
+			return
+		}
+		if sc.NoRestart {
+			c.Logger.Warn(fmt.Sprintf("sidecar %s exited: %v", sc.Name, err))
+			return
+		}
+		if time.Since(startedAt) >= sidecarStableAfter {
+			failures = 0
+		}
+		delay := getSidecarRestartDelay(failures)
+
+		c.sidecars.mu.Lock()
+		defer c.sidecars.mu.Unlock()
+		if c.sidecars.v == nil || c.sidecarsGen != gen {
+			return
+		}
+		c.Logger.Warn(fmt.Sprintf("sidecar %s exited (%v), restarting in %s", sc.Name, err, delay))
+		c.scheduleSidecarRestartLocked(sc, failures, gen, delay)
+	}()
+}
+
+// scheduleSidecarRestartLocked must be called with c.sidecars.mu held. The
+// timer is tracked so that stopSidecarsDev can cancel it.
+func (c *Config) scheduleSidecarRestartLocked(sc *DevSidecar, failures int, gen uint64, delay time.Duration) {
+	c.sidecarRestarts[sc.Name] = time.AfterFunc(delay, func() { c.startSidecarDev(sc, failures+1, gen) })
+}
+
+// stopSidecarsDev terminates every sidecar concurrently, the same way the
+// app is terminated (see killAppDev), and cancels any pending restarts.
+func (c *Config) stopSidecarsDev() {
+	c.sidecars.mu.Lock()
+	procs := c.sidecars.v
+	c.sidecars.v = nil
+	c.sidecarsGen++
+	for _, timer := range c.sidecarRestarts {
+		timer.Stop()
+	}
+	c.sidecarRestarts = nil
+	c.sidecars.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for name, p := range procs {
+		p.stopping.Store(true)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-p.done:
+				return
+			default:
+			}
+			// The process is reaped by its own goroutine, so any error
+			// here is only worth reporting if it's still running
+			err := grace.TerminateProcess(p.cmd.Process, sidecarStopTimeout, c.Logger)
+			select {
+			case <-p.done:
+				c.Logger.Info("Terminated sidecar", "name", name, "pid", p.cmd.Process.Pid)
+			case <-time.After(sidecarStopTimeout):
+				c.Logger.Error(fmt.Sprintf("error: failed to terminate sidecar %s: %v", name, err))
+				p.cmd.Process.Kill()
+				<-p.done
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,140 @@
+package ik
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetSidecarRestartDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 500 * time.Millisecond},
+		{1, time.Second},
+		{3, 4 * time.Second},
+		{6, 30 * time.Second},
+		{100, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := getSidecarRestartDelay(tt.failures); got != tt.want {
+			t.Errorf("getSidecarRestartDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSidecars(t *testing.T) {
+	if err := validateSidecars([]DevSidecar{{Name: "css", Command: "npx"}, {Name: "api", Command: "node", Env: []string{"PORT=1"}}}); err != nil {
+		t.Errorf("validateSidecars() error = %v, want nil", err)
+	}
+	for _, sidecars := range [][]DevSidecar{
+		{{Name: "css"}},
+		{{Command: "npx"}},
+		{{Name: "css", Command: "npx"}, {Name: "css", Command: "node"}},
+		{{Name: "css", Command: "npx", Env: []string{"PORT"}}},
+	} {
+		if err := validateSidecars(sidecars); err == nil {
+			t.Errorf("validateSidecars(%v) error = nil, want error", sidecars)
+		}
+	}
+}
+
+func TestSidecarsDev(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	crashLog := filepath.Join(testRootDir, "crash.log")
+	env.createTestFile(t, "crash.log", "")
+
+	env.config.devConfig = &DevConfig{Sidecars: []DevSidecar{
+		{Name: "crasher", Command: "sh", Args: []string{"-c", "echo run >> " + crashLog + "; exit 1"}},
+		{Name: "sleeper", Command: "sh", Args: []string{"-c", "exec sleep 60"}},
+	}}
+
+	env.config.startSidecarsDev()
+
+	getRuns := func() int {
+		content, _ := os.ReadFile(crashLog)
+		return strings.Count(string(content), "run")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for getRuns() < 2 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if runs := getRuns(); runs < 2 {
+		t.Fatalf("crasher ran %d times, want it restarted after crashing", runs)
+	}
+
+	env.config.sidecars.mu.Lock()
+	sleeper := env.config.sidecars.v["sleeper"]
+	env.config.sidecars.mu.Unlock()
+	if sleeper == nil {
+		t.Fatalf("sleeper is not running after startSidecarsDev()")
+	}
+
+	start := time.Now()
+	env.config.stopSidecarsDev()
+	if elapsed := time.Since(start); elapsed >= sidecarStopTimeout {
+		t.Errorf("stopSidecarsDev() took %v, want a graceful stop", elapsed)
+	}
+	select {
+	case <-sleeper.done:
+	default:
+		t.Errorf("sleeper is still running after stopSidecarsDev()")
+	}
+
+	// No restarts are scheduled once stopped
+	runs := getRuns()
+	time.Sleep(2 * time.Second)
+	if got := getRuns(); got != runs {
+		t.Errorf("crasher ran %d times after stopSidecarsDev(), want %d", got, runs)
+	}
+}
+
+func TestSidecarRestartsDontOutliveTheirRun(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.config.devConfig = &DevConfig{Sidecars: []DevSidecar{
+		{Name: "sleeper", Command: "sh", Args: []string{"-c", "exec sleep 60"}},
+		{Name: "missing", Command: filepath.Join(testRootDir, "does-not-exist")},
+	}}
+
+	env.config.startSidecarsDev()
+	env.config.sidecars.mu.Lock()
+	staleGen := env.config.sidecarsGen
+	pending := len(env.config.sidecarRestarts)
+	env.config.sidecars.mu.Unlock()
+	if pending != 1 {
+		t.Fatalf("pending restarts = %d, want 1 for the sidecar that failed to start", pending)
+	}
+	env.config.stopSidecarsDev()
+
+	env.config.startSidecarsDev()
+	defer env.config.stopSidecarsDev()
+	env.config.sidecars.mu.Lock()
+	sleeper := env.config.sidecars.v["sleeper"]
+	env.config.sidecars.mu.Unlock()
+
+	// A restart left over from the first run must not touch the second
+	env.config.startSidecarDev(&env.config.devConfig.Sidecars[0], 0, staleGen)
+	env.config.sidecars.mu.Lock()
+	got := env.config.sidecars.v["sleeper"]
+	env.config.sidecars.mu.Unlock()
+	if got != sleeper {
+		t.Errorf("stale restart replaced the running sleeper")
+	}
+}
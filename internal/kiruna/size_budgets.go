@@ -0,0 +1,221 @@
+package ik
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// SizeBudget caps the size of an asset. A zero value means no limit.
+type SizeBudget struct {
+	MaxBytes           int64
+	MaxCompressedBytes int64 // Measured with gzip, as the lowest common denominator
+}
+
+type SizeBudgets struct {
+	// Keyed by glob pattern (doublestar syntax), relative to PublicStaticDir
+	// (e.g., "images/**/*.png"). Every public file matching a pattern must fit
+	// that pattern's budget. Script entries are matched as "<name>.js".
+	PublicFiles map[string]SizeBudget
+
+	// The normal CSS bundle, as served from the public dir
+	NormalCSS SizeBudget
+
+	// The critical CSS bundle, which is inlined into every page
+	// through GetCriticalCSSStyleElement
+	CriticalCSS SizeBudget
+}
+
+func (b SizeBudget) isZero() bool {
+	return b.MaxBytes == 0 && b.MaxCompressedBytes == 0
+}
+
+type sizeBudgetViolation struct {
+	Budget  string `json:"budget"`
+	Asset   string `json:"asset"`
+	Measure string `json:"measure"` // "raw" or "gzip"
+	Size    int64  `json:"size"`
+	Limit   int64  `json:"limit"`
+}
+
+type sizeBudgetError struct {
+	violations []sizeBudgetViolation
+}
+
+func (e *sizeBudgetError) Error() string {
+	return fmt.Sprintf("size budgets exceeded:\n%s", formatSizeBudgetViolations(e.violations))
+}
+
+func formatSizeBudgetViolations(violations []sizeBudgetViolation) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BUDGET\tASSET\tMEASURE\tSIZE\tLIMIT\tOVER")
+	for _, v := range violations {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t+%d\n", v.Budget, v.Asset, v.Measure, v.Size, v.Limit, v.Size-v.Limit)
+	}
+	w.Flush()
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+func (c *Config) validateSizeBudgets() {
+	for pattern := range c.SizeBudgets.PublicFiles {
+		if !doublestar.ValidatePattern(pattern) {
+			panic(fmt.Sprintf("invalid glob pattern (%q) in kiruna.Config.SizeBudgets.PublicFiles", pattern))
+		}
+	}
+}
+
+// checkSizeBudgets returns every budget the assets in result exceed, in a
+// stable order (assets in result are already sorted).
+func (c *Config) checkSizeBudgets(result *BuildResult) ([]sizeBudgetViolation, error) {
+	var violations []sizeBudgetViolation
+
+	scriptKeysByEntry := make(map[string]string, len(c.cleanSources.ScriptEntries))
+	for name, entry := range c.cleanSources.ScriptEntries {
+		scriptKeysByEntry[filepath.ToSlash(entry)] = scriptFileMapKey(name)
+	}
+
+	patterns := make([]string, 0, len(c.SizeBudgets.PublicFiles))
+	for pattern := range c.SizeBudgets.PublicFiles {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	for _, asset := range result.Assets {
+		key := asset.OriginalPath
+		switch asset.Kind {
+		case BuildAssetKindPublic:
+		case BuildAssetKindScript:
+			key = scriptKeysByEntry[asset.OriginalPath]
+		default:
+			continue
+		}
+		for _, pattern := range patterns {
+			if isMatch, _ := doublestar.Match(pattern, key); !isMatch {
+				continue
+			}
+			v, err := c.checkSizeBudget("public: "+pattern, key, asset, c.SizeBudgets.PublicFiles[pattern])
+			if err != nil {
+				return nil, err
+			}
+			violations = append(violations, v...)
+		}
+	}
+
+	for _, bundle := range result.CSSBundles {
+		budget, label := c.SizeBudgets.NormalCSS, "normal CSS"
+		if bundle.Nature == "critical" {
+			budget, label = c.SizeBudgets.CriticalCSS, "critical CSS"
+		}
+		v, err := c.checkSizeBudget(label, bundle.OriginalPath, bundle.BuildAsset, budget)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, v...)
+	}
+
+	return violations, nil
+}
+
+func (c *Config) checkSizeBudget(label, name string, asset BuildAsset, budget SizeBudget) ([]sizeBudgetViolation, error) {
+	if budget.isZero() {
+		return nil, nil
+	}
+
+	var violations []sizeBudgetViolation
+
+	if budget.MaxBytes > 0 && asset.Size > budget.MaxBytes {
+		violations = append(violations, sizeBudgetViolation{
+			Budget: label, Asset: name, Measure: "raw", Size: asset.Size, Limit: budget.MaxBytes,
+		})
+	}
+
+	if budget.MaxCompressedBytes > 0 {
+		gzipSize, ok := asset.CompressedSizes["gzip"]
+		if !ok {
+			// Not computed for dev builds or non-compressible types
+			var err error
+			if gzipSize, err = c.getGzipSize(asset.HashedPath); err != nil {
+				return nil, err
+			}
+		}
+		if gzipSize > budget.MaxCompressedBytes {
+			violations = append(violations, sizeBudgetViolation{
+				Budget: label, Asset: name, Measure: "gzip", Size: gzipSize, Limit: budget.MaxCompressedBytes,
+			})
+		}
+	}
+
+	return violations, nil
+}
+
+func (c *Config) getGzipSize(hashedPath string) (int64, error) {
+	content, err := os.ReadFile(filepath.Join(c.__dist.S().Kiruna.FullPath(), filepath.FromSlash(hashedPath)))
+	if err != nil {
+		return 0, fmt.Errorf("error reading asset to measure: %v", err)
+	}
+	for _, enc := range precompressedEncodings {
+		if enc.name != "gzip" {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := enc.compress(&buf, content); err != nil {
+			return 0, fmt.Errorf("error compressing %s: %v", hashedPath, err)
+		}
+		return int64(buf.Len()), nil
+	}
+	return 0, nil
+}
+
+// enforceSizeBudgets fails in prod if any budget is exceeded. In dev, it
+// only warns, both in the terminal and in the browser console.
+func (c *Config) enforceSizeBudgets(result *BuildResult) error {
+	violations, err := c.checkSizeBudgets(result)
+	if err != nil {
+		return fmt.Errorf("error checking size budgets: %w", err)
+	}
+
+	if !c.getIsDev() {
+		if len(violations) > 0 {
+			return &sizeBudgetError{violations: violations}
+		}
+		return nil
+	}
+
+	if len(violations) > 0 {
+		c.Logger.Warn(fmt.Sprintf("size budgets exceeded:\n%s", formatSizeBudgetViolations(violations)))
+	}
+	if c.manager != nil && !c.ServerOnly {
+		c.manager.setBudgetWarnings(violations)
+	}
+	return nil
+}
+
+func (c *Config) hasSizeBudgets() bool {
+	return len(c.SizeBudgets.PublicFiles) > 0 || !c.SizeBudgets.NormalCSS.isZero() || !c.SizeBudgets.CriticalCSS.isZero()
+}
+
+// reportSizeBudgetsDev re-checks budgets after a CSS hot reload, which
+// bypasses Build.
+func (c *Config) reportSizeBudgetsDev() {
+	if !c.hasSizeBudgets() {
+		return
+	}
+	result, err := c.assembleBuildResult(BuildTimings{}, false, true)
+	if err == nil {
+		err = c.enforceSizeBudgets(result)
+	}
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error: %v", err))
+		return
+	}
+	if rfp := c.manager.getBudgetWarningsPayload(); rfp != nil {
+		c.manager.broadcastPayload(*rfp)
+	}
+}
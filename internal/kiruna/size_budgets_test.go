@@ -0,0 +1,48 @@
+package ik
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSizeBudgets(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "critical.css", strings.Repeat("body { color: red; }\n", 50))
+	env.createTestFile(t, "main.css", "p { font-size: 16px; }")
+	env.createTestFile(t, "public-static/images/big.svg", strings.Repeat("<svg></svg>", 100))
+	env.createTestFile(t, "public-static/images/small.svg", "<svg></svg>")
+
+	env.config.SizeBudgets = SizeBudgets{
+		PublicFiles: map[string]SizeBudget{"images/**/*.svg": {MaxBytes: 100}},
+		CriticalCSS: SizeBudget{MaxCompressedBytes: 10},
+		NormalCSS:   SizeBudget{MaxBytes: 1000},
+	}
+
+	_, err := env.config.Build(false, false)
+	var budgetErr *sizeBudgetError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("Build() error = %v, want size budget error", err)
+	}
+
+	if len(budgetErr.violations) != 2 {
+		t.Fatalf("violations = %+v, want 2", budgetErr.violations)
+	}
+	for _, want := range []string{"images/big.svg", "critical CSS", "gzip", "LIMIT"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error table is missing %q:\n%v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "small.svg") || strings.Contains(err.Error(), "normal CSS") {
+		t.Errorf("error table lists assets within budget:\n%v", err)
+	}
+
+	// In dev, violations only warn
+	os.Setenv(modeKey, devModeVal)
+	if _, err := env.config.Build(false, false); err != nil {
+		t.Errorf("Build() in dev error = %v, want nil", err)
+	}
+}
@@ -3,7 +3,9 @@ package ik
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -11,24 +13,67 @@ import (
 type fileVal struct {
 	Val         string
 	IsPrehashed bool
+	Integrity   string // SRI digest, only set for public files
 }
 
 type FileMap map[string]fileVal
 
+func withTrailingSlash(s string) string {
+	if strings.HasSuffix(s, "/") {
+		return s
+	}
+	return s + "/"
+}
+
+// GetPublicPathPrefix returns the URL path prefix under which public files
+// are served (always with leading and trailing slashes), for mounting the
+// handler returned by GetServeStaticHandler.
+func (c *Config) GetPublicPathPrefix() string {
+	return c.publicPathPrefix
+}
+
+// getPublicURLBase returns what hashed public filenames are appended to
+// in order to make public URLs.
+func (c *Config) getPublicURLBase() string {
+	if c.publicAssetBaseURL != "" && !c.getIsDev() {
+		return c.publicAssetBaseURL
+	}
+	return c.publicPathPrefix
+}
+
+// GetServeStaticHandler returns a handler serving public files, which strips
+// Config.PublicPathPrefix from request paths. The pathPrefix arg may be left
+// blank, and is otherwise only checked against Config.PublicPathPrefix.
+// Every known file gets a strong ETag, so conditional requests are answered
+// with 304s. If addImmutableCacheHeaders is true, content-hashed files are
+// marked immutable, and the rest (e.g., prehashed files) must be revalidated.
+// Config.PublicCacheControl overrides either behavior.
 func (c *Config) GetServeStaticHandler(pathPrefix string, addImmutableCacheHeaders bool) (http.Handler, error) {
+	if pathPrefix != "" && withTrailingSlash(pathPrefix) != c.publicPathPrefix {
+		errMsg := fmt.Sprintf(
+			"error: GetServeStaticHandler pathPrefix (%s) disagrees with Config.PublicPathPrefix (%s)",
+			pathPrefix, c.publicPathPrefix,
+		)
+		c.Logger.Error(errMsg)
+		return nil, errors.New(errMsg)
+	}
+	return c.getServeStaticHandler(&StaticHandlerOptions{AddImmutableCacheHeaders: addImmutableCacheHeaders})
+}
+
+func (c *Config) getServeStaticHandler(opts *StaticHandlerOptions) (http.Handler, error) {
 	publicFS, err := c.GetPublicFS()
 	if err != nil {
 		errMsg := fmt.Sprintf("error getting public FS: %v", err)
 		c.Logger.Error(errMsg)
 		return nil, errors.New(errMsg)
 	}
-	if addImmutableCacheHeaders {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
-			http.StripPrefix(pathPrefix, http.FileServer(http.FS(publicFS))).ServeHTTP(w, r)
-		}), nil
+	fileServer := http.FileServer(http.FS(publicFS))
+	if c.PrecompressPublicFiles {
+		fileServer = newPrecompressedFileServer(publicFS, fileServer)
 	}
-	return http.StripPrefix(pathPrefix, http.FileServer(http.FS(publicFS))), nil
+	return http.StripPrefix(c.publicPathPrefix, c.withStaticFallbacks(
+		c.withStaticCacheHeaders(fileServer, opts.AddImmutableCacheHeaders), opts,
+	)), nil
 }
 
 func (c *Config) getInitialPublicFileMapFromGobBuildtime() (FileMap, error) {
@@ -65,7 +110,7 @@ func (c *Config) getInitialPublicURL(originalPublicURL string) (string, error) {
 		c.Logger.Error(fmt.Sprintf(
 			"error getting public file map from gob for originalPublicURL %s: %v", originalPublicURL, err,
 		))
-		return "/" + PUBLIC + "/" + originalPublicURL, err
+		return c.getPublicURLBase() + cleanURL(originalPublicURL), err
 	}
 
 	return c.getInitialPublicURLInner(originalPublicURL, fileMapFromGob)
@@ -77,7 +122,7 @@ func (c *Config) getInitialPublicURLInner(originalPublicURL string, fileMapFromG
 	}
 
 	if hashedURL, existsInFileMap := fileMapFromGob[cleanURL(originalPublicURL)]; existsInFileMap {
-		return "/" + PUBLIC + "/" + hashedURL.Val, nil
+		return c.getPublicURLBase() + hashedURL.Val, nil
 	}
 
 	// If no hashed URL found, return the original URL
@@ -86,7 +131,7 @@ func (c *Config) getInitialPublicURLInner(originalPublicURL string, fileMapFromG
 		originalPublicURL,
 	))
 
-	return "/" + PUBLIC + "/" + originalPublicURL, nil
+	return c.getPublicURLBase() + cleanURL(originalPublicURL), nil
 }
 
 func publicURLsKeyMaker(x string) string { return x }
@@ -96,6 +141,38 @@ func (c *Config) GetPublicURL(originalPublicURL string) string {
 	return url
 }
 
+// GetPublicAssetIntegrity returns the Subresource Integrity digest (sha384) of
+// the given public file, for use in the integrity attribute of hand-written tags
+// (alongside crossorigin="anonymous"). Returns an empty string if not found.
+func (c *Config) GetPublicAssetIntegrity(originalPublicURL string) string {
+	fileMapFromGob, err := c.runtimeCache.publicFileMapFromGob.Get()
+	if err != nil {
+		return ""
+	}
+	return fileMapFromGob[cleanURL(originalPublicURL)].Integrity
+}
+
+// getPublicDistFileIntegrity returns the SRI digest of a file Kiruna itself
+// generated into the public dist dir (and which therefore isn't in the file map).
+func (c *Config) getPublicDistFileIntegrity(fileName string) (string, error) {
+	baseFS, err := c.GetBaseFS()
+	if err != nil {
+		return "", fmt.Errorf("error getting FS: %v", err)
+	}
+
+	// __LOCATION_ASSUMPTION: Inside "dist/kiruna"
+	content, err := fs.ReadFile(baseFS, path.Join(
+		c.__dist.S().Kiruna.S().Static.LastSegment(),
+		c.__dist.S().Kiruna.S().Static.S().Public.LastSegment(),
+		fileName,
+	))
+	if err != nil {
+		return "", fmt.Errorf("error reading public file %s: %v", fileName, err)
+	}
+
+	return getSRIDigest(content), nil
+}
+
 func cleanURL(url string) string {
 	return strings.TrimPrefix(filepath.Clean(url), "/")
 }
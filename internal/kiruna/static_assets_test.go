@@ -0,0 +1,273 @@
+package ik
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPublicURLPrefixAndBaseURL(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.config.publicPathPrefix = "/assets/"
+	env.config.publicAssetBaseURL = "https://cdn.example.com/assets/"
+
+	env.createTestFile(t, "public-static/logo.svg", "<svg></svg>")
+	env.createTestFile(t, "critical.css", "p { margin: 0; }")
+	env.createTestFile(t, "main.css", "body { background: url('/logo.svg'); }")
+
+	if _, err := env.config.Build(false, false); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	logoURL := env.config.GetPublicURL("logo.svg")
+	if !strings.HasPrefix(logoURL, "https://cdn.example.com/assets/logo_") {
+		t.Errorf("GetPublicURL() = %v, want CDN URL", logoURL)
+	}
+
+	cssBytes, err := os.ReadFile(filepath.Join(testRootDir, "dist/kiruna/static/public", strings.TrimPrefix(
+		env.config.GetStyleSheetURL(), "https://cdn.example.com/assets/",
+	)))
+	if err != nil {
+		t.Fatalf("failed to read CSS output: %v", err)
+	}
+	if !strings.Contains(string(cssBytes), logoURL) {
+		t.Errorf("CSS url() = %s, want it to reference %v", cssBytes, logoURL)
+	}
+
+	details, err := env.config.getInitialPublicFileMapDetails()
+	if err != nil {
+		t.Fatalf("getInitialPublicFileMapDetails() error = %v", err)
+	}
+	if got := string(details.Elements); !strings.Contains(got, `"https://cdn.example.com/assets/"`) {
+		t.Errorf("public file map elements = %v, want them to use the CDN base URL", got)
+	}
+
+	if _, err := env.config.GetServeStaticHandler("/public/", false); err == nil {
+		t.Errorf("GetServeStaticHandler() with disagreeing prefix error = nil, want error")
+	}
+	handler, err := env.config.GetServeStaticHandler("", false)
+	if err != nil {
+		t.Fatalf("GetServeStaticHandler() error = %v", err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/assets/"+strings.TrimPrefix(logoURL, "https://cdn.example.com/assets/"), nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("static handler status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// The CDN is never used in dev
+	os.Setenv(modeKey, devModeVal)
+	if got := env.config.getPublicURLBase(); got != "/assets/" {
+		t.Errorf("getPublicURLBase() in dev = %v, want /assets/", got)
+	}
+}
+
+func TestGetPublicAssetIntegrity(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "public-static/app.js", "console.log('hi');")
+	env.createTestFile(t, "public-static/prehashed/lib.js", "console.log('lib');")
+	env.createTestFile(t, "private-static/secret.txt", "secret")
+
+	if err := env.config.SetupDistDir(); err != nil {
+		t.Fatalf("SetupDistDir() error = %v", err)
+	}
+	if err := env.config.handlePublicFiles(false); err != nil {
+		t.Fatalf("handlePublicFiles() error = %v", err)
+	}
+	if err := env.config.copyPrivateFiles(false); err != nil {
+		t.Fatalf("copyPrivateFiles() error = %v", err)
+	}
+
+	if got, want := env.config.GetPublicAssetIntegrity("/app.js"), getSRIDigest([]byte("console.log('hi');")); got != want {
+		t.Errorf("GetPublicAssetIntegrity(app.js) = %v, want %v", got, want)
+	}
+	if !strings.HasPrefix(env.config.GetPublicAssetIntegrity("lib.js"), "sha384-") {
+		t.Errorf("GetPublicAssetIntegrity(lib.js) = %v, want sha384 digest", env.config.GetPublicAssetIntegrity("lib.js"))
+	}
+	if got := env.config.GetPublicAssetIntegrity("missing.js"); got != "" {
+		t.Errorf("GetPublicAssetIntegrity(missing.js) = %v, want empty", got)
+	}
+
+	privateMap, err := env.config.loadMapFromGob(PrivateFileMapGobName, true)
+	if err != nil {
+		t.Fatalf("failed to load private file map: %v", err)
+	}
+	if privateMap["secret.txt"].Integrity != "" {
+		t.Errorf("private file has an SRI digest, want none")
+	}
+
+	details, err := env.config.getInitialPublicFileMapDetails()
+	if err != nil {
+		t.Fatalf("getInitialPublicFileMapDetails() error = %v", err)
+	}
+	if !strings.Contains(string(details.Elements), `integrity="sha384-`) {
+		t.Errorf("modulepreload link is missing integrity: %v", details.Elements)
+	}
+}
+
+func TestServeStaticCacheHeaders(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "public-static/app.js", "console.log('hi');")
+	env.createTestFile(t, "public-static/prehashed/robots.txt", "User-agent: *")
+	env.createTestFile(t, "public-static/images/hero.png", "png")
+	env.createTestFile(t, "critical.css", "p { margin: 0; }")
+	env.createTestFile(t, "main.css", "body { margin: 0; }")
+
+	env.config.PublicCacheControl = map[string]string{
+		"images/**": "public, max-age=60",
+	}
+
+	if _, err := env.config.Build(false, false); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	handler, err := env.config.GetServeStaticHandler("", true)
+	if err != nil {
+		t.Fatalf("GetServeStaticHandler() error = %v", err)
+	}
+
+	serve := func(url, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		url          string
+		cacheControl string
+	}{
+		{env.config.GetPublicURL("app.js"), immutableCacheControl},
+		{env.config.GetPublicURL("robots.txt"), "no-cache"},
+		{env.config.GetPublicURL("images/hero.png"), "public, max-age=60"},
+		{env.config.GetStyleSheetURL(), immutableCacheControl},
+		{env.config.GetPublicFileMapURL(), immutableCacheControl},
+	}
+
+	for _, tt := range tests {
+		rec := serve(tt.url, "")
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want %d", tt.url, rec.Code, http.StatusOK)
+			continue
+		}
+		if got := rec.Header().Get("Cache-Control"); got != tt.cacheControl {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.url, got, tt.cacheControl)
+		}
+		etag := rec.Header().Get("ETag")
+		if !strings.HasPrefix(etag, `"sha384-`) {
+			t.Errorf("%s: ETag = %q, want strong sha384 ETag", tt.url, etag)
+			continue
+		}
+		if rec := serve(tt.url, etag); rec.Code != http.StatusNotModified {
+			t.Errorf("%s: conditional status = %d, want %d", tt.url, rec.Code, http.StatusNotModified)
+		}
+	}
+
+	if got, want := serve(env.config.GetPublicURL("robots.txt"), "").Header().Get("ETag"),
+		toStrongETag(getSRIDigest([]byte("User-agent: *"))); got != want {
+		t.Errorf("prehashed ETag = %q, want %q", got, want)
+	}
+
+	rec := serve("/public/missing.txt", "")
+	if rec.Code != http.StatusNotFound || rec.Header().Get("ETag") != "" || rec.Header().Get("Cache-Control") != "" {
+		t.Errorf("missing file: status = %d, headers = %v", rec.Code, rec.Header())
+	}
+
+	// Unknown paths must not grow the index
+	entries, _ := env.config.runtimeCache.staticCacheEntries.Get()
+	for i := range 10 {
+		serve(fmt.Sprintf("/public/bogus-%d.txt", i), "")
+	}
+	if after, _ := env.config.runtimeCache.staticCacheEntries.Get(); len(after) != len(entries) {
+		t.Errorf("static cache entries grew from %d to %d after serving unknown paths", len(entries), len(after))
+	}
+
+	handler, err = env.config.GetServeStaticHandler("", false)
+	if err != nil {
+		t.Fatalf("GetServeStaticHandler() error = %v", err)
+	}
+	if got := serve(env.config.GetPublicURL("app.js"), "").Header().Get("Cache-Control"); got != "" {
+		t.Errorf("Cache-Control without immutable headers = %q, want none", got)
+	}
+}
+
+func TestServeStaticHandlerFallbacks(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "public-static/admin/index.html", "<html>admin</html>")
+	env.createTestFile(t, "public-static/docs/guide.txt", "guide")
+	env.createTestFile(t, "private-static/404.html", "<html>not found</html>")
+
+	if err := env.config.SetupDistDir(); err != nil {
+		t.Fatalf("SetupDistDir() error = %v", err)
+	}
+	if err := env.config.handlePublicFiles(false); err != nil {
+		t.Fatalf("handlePublicFiles() error = %v", err)
+	}
+	if err := env.config.copyPrivateFiles(false); err != nil {
+		t.Fatalf("copyPrivateFiles() error = %v", err)
+	}
+
+	handler, err := env.config.GetServeStaticHandlerWithOptions(&StaticHandlerOptions{
+		DisableDirectoryListings: true,
+		NotFoundFile:             &StaticFileRef{Path: "404.html", IsPrivate: true},
+		SPAFallbacks: map[string]StaticFileRef{
+			"/admin": {Path: "admin/index.html"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("GetServeStaticHandlerWithOptions() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		body   string
+	}{
+		{"ExistingFile", env.config.GetPublicURL("docs/guide.txt"), http.StatusOK, "guide"},
+		{"SPARoot", "/public/admin", http.StatusOK, "<html>admin</html>"},
+		{"SPADeepLink", "/public/admin/users/42", http.StatusOK, "<html>admin</html>"},
+		{"SPAMissingAsset", "/public/admin/missing.js", http.StatusNotFound, "<html>not found</html>"},
+		{"OutsideSPA", "/public/other/page", http.StatusNotFound, "<html>not found</html>"},
+		{"DirectoryListing", "/public/", http.StatusNotFound, "<html>not found</html>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if rec.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.body)
+			}
+		})
+	}
+
+	// Without options, the stdlib file server behavior is unchanged
+	handler, err = env.config.GetServeStaticHandler("", false)
+	if err != nil {
+		t.Fatalf("GetServeStaticHandler() error = %v", err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/public/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<a href=") {
+		t.Errorf("directory listing: status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,224 @@
+package ik
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+type staticCacheEntry struct {
+	originalPath string // Empty for files Kiruna generates itself
+	isHashed     bool   // Whether the served filename changes whenever the content does
+	etag         string
+}
+
+type publicDistFile struct {
+	originalPath string
+	val          fileVal
+}
+
+// getInitialPublicFileMapByDistName indexes the public file map by the name
+// each file is served under.
+func (c *Config) getInitialPublicFileMapByDistName() (map[string]publicDistFile, error) {
+	fileMapFromGob, err := c.runtimeCache.publicFileMapFromGob.Get()
+	if err != nil {
+		return nil, fmt.Errorf("error getting public file map: %v", err)
+	}
+	byDistName := make(map[string]publicDistFile, len(fileMapFromGob))
+	for originalPath, val := range fileMapFromGob {
+		byDistName[val.Val] = publicDistFile{originalPath: originalPath, val: val}
+	}
+	return byDistName, nil
+}
+
+// Kinds of public files Kiruna generates itself, which are content-hashed
+// but not in the public file map
+const (
+	generatedPublicNormalCSS = "normal-css"
+	generatedPublicFileMap   = "public-file-map"
+)
+
+type generatedPublicFile struct {
+	Name      string `json:"name"` // Served name, relative to the public dist dir
+	Integrity string `json:"integrity"`
+}
+
+// recordGeneratedPublicFile saves the digest of a generated public file at
+// build time, so that the static handler never needs to read it to get an ETag.
+func (c *Config) recordGeneratedPublicFile(kind, distName string, content []byte) error {
+	c.generatedPublicMu.Lock()
+	defer c.generatedPublicMu.Unlock()
+
+	refPath := c.__dist.S().Kiruna.S().Internal.S().GeneratedPublicDotJSON.FullPath()
+
+	files := map[string]generatedPublicFile{}
+	if existing, err := os.ReadFile(refPath); err == nil {
+		if err := json.Unmarshal(existing, &files); err != nil {
+			return fmt.Errorf("error unmarshalling generated public files: %v", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error reading generated public files: %v", err)
+	}
+
+	files[kind] = generatedPublicFile{Name: distName, Integrity: getSRIDigest(content)}
+
+	refBytes, err := json.Marshal(files)
+	if err != nil {
+		return fmt.Errorf("error marshalling generated public files: %v", err)
+	}
+	if err := os.WriteFile(refPath, refBytes, 0644); err != nil {
+		return fmt.Errorf("error writing generated public files: %v", err)
+	}
+	return nil
+}
+
+func (c *Config) readGeneratedPublicFiles() (map[string]generatedPublicFile, error) {
+	baseFS, err := c.GetBaseFS()
+	if err != nil {
+		return nil, fmt.Errorf("error getting FS: %v", err)
+	}
+
+	distKirunaInternal := c.__dist.S().Kiruna.S().Internal
+
+	// __LOCATION_ASSUMPTION: Inside "dist/kiruna"
+	content, err := fs.ReadFile(baseFS, path.Join(
+		distKirunaInternal.LastSegment(),
+		distKirunaInternal.S().GeneratedPublicDotJSON.LastSegment(),
+	))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading generated public files: %v", err)
+	}
+
+	var files map[string]generatedPublicFile
+	if err := json.Unmarshal(content, &files); err != nil {
+		return nil, fmt.Errorf("error unmarshalling generated public files: %v", err)
+	}
+	return files, nil
+}
+
+// getInitialStaticCacheEntries indexes every file the static handler knows
+// about by its served name. Anything else gets no cache headers, which keeps
+// the index bounded no matter what clients request.
+func (c *Config) getInitialStaticCacheEntries() (map[string]*staticCacheEntry, error) {
+	// There is no public file map if there is no public static dir, but Kiruna
+	// may still serve generated files, so carry on without it
+	byDistName, _ := c.runtimeCache.publicFileMapByDistName.Get()
+
+	generated, err := c.readGeneratedPublicFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]*staticCacheEntry, len(byDistName)+len(generated))
+	for distName, file := range byDistName {
+		entry := &staticCacheEntry{originalPath: file.originalPath, isHashed: !file.val.IsPrehashed}
+		if file.val.Integrity != "" {
+			entry.etag = toStrongETag(file.val.Integrity)
+		}
+		entries[distName] = entry
+	}
+	for _, file := range generated {
+		entries[file.Name] = &staticCacheEntry{isHashed: true, etag: toStrongETag(file.Integrity)}
+	}
+	return entries, nil
+}
+
+// toStrongETag quotes an SRI digest, which is already a strong validator
+// of the file's content.
+func toStrongETag(sriDigest string) string {
+	return `"` + sriDigest + `"`
+}
+
+// withETagEncoding gives each precompressed representation of a file its own
+// strong ETag, as required for byte-for-byte comparisons.
+func withETagEncoding(etag, contentEncoding string) string {
+	if etag == "" || contentEncoding == "" || !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "." + contentEncoding + `"`
+}
+
+func (c *Config) validatePublicCacheControl() {
+	for pattern := range c.PublicCacheControl {
+		if !doublestar.ValidatePattern(pattern) {
+			panic(fmt.Sprintf("invalid glob pattern (%q) in kiruna.Config.PublicCacheControl", pattern))
+		}
+	}
+}
+
+// getCacheControl returns the Cache-Control value for entry, if any. Patterns
+// in Config.PublicCacheControl are matched against the original path (or the
+// served name, for files Kiruna generates), and the longest match wins.
+func (c *Config) getCacheControl(entry *staticCacheEntry, distName string, addImmutableCacheHeaders bool) string {
+	matchPath := entry.originalPath
+	if matchPath == "" {
+		matchPath = distName
+	}
+
+	patterns := make([]string, 0, len(c.PublicCacheControl))
+	for pattern := range c.PublicCacheControl {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	for _, pattern := range patterns {
+		if isMatch, _ := doublestar.Match(pattern, matchPath); isMatch {
+			return c.PublicCacheControl[pattern]
+		}
+	}
+
+	if !addImmutableCacheHeaders {
+		return ""
+	}
+	if entry.isHashed {
+		return immutableCacheControl
+	}
+	// Prehashed files keep their names across content changes, so browsers
+	// must revalidate them (cheaply, thanks to the ETag)
+	return "no-cache"
+}
+
+// withStaticCacheHeaders sets ETag and Cache-Control headers on responses for
+// known public files, and lets next (ultimately http.ServeContent) answer
+// conditional requests. Expects the path prefix to already be stripped.
+func (c *Config) withStaticCacheHeaders(next http.Handler, addImmutableCacheHeaders bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		distName := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if distName == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		entries, err := c.runtimeCache.staticCacheEntries.Get()
+		if err != nil {
+			c.Logger.Error(fmt.Sprintf("error getting static cache entries: %v", err))
+		}
+		if entry := entries[distName]; entry != nil {
+			if entry.etag != "" {
+				w.Header().Set("ETag", entry.etag)
+			}
+			if cacheControl := c.getCacheControl(entry, distName, addImmutableCacheHeaders); cacheControl != "" {
+				w.Header().Set("Cache-Control", cacheControl)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,176 @@
+package ik
+
+import (
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+type StaticHandlerOptions struct {
+	// See GetServeStaticHandler
+	AddImmutableCacheHeaders bool
+
+	// If true, requests for directories without an index.html get a 404
+	// instead of a listing of the directory's files.
+	DisableDirectoryListings bool
+
+	// Optional. Served, with a 404 status, in place of the stdlib's plain
+	// text response when a requested file doesn't exist.
+	NotFoundFile *StaticFileRef
+
+	// Optional. Keyed by URL path prefix, relative to Config.PublicPathPrefix
+	// (e.g., "admin/", or "" to match everything). Requests under a prefix for
+	// paths that don't exist and don't look like assets (i.e., whose last
+	// segment has no file extension) are answered with the given file, with a
+	// 200 status, so that client-side routers can take over. If several
+	// prefixes match, the longest one wins.
+	SPAFallbacks map[string]StaticFileRef
+}
+
+// StaticFileRef points to a file by its original path, relative to
+// PublicStaticDir (e.g., "admin/index.html"), or to PrivateStaticDir if
+// IsPrivate is true.
+type StaticFileRef struct {
+	Path      string
+	IsPrivate bool
+}
+
+// GetServeStaticHandlerWithOptions is like GetServeStaticHandler, with
+// additional control over directory listings and unmatched paths.
+func (c *Config) GetServeStaticHandlerWithOptions(opts *StaticHandlerOptions) (http.Handler, error) {
+	if opts == nil {
+		opts = &StaticHandlerOptions{}
+	}
+	return c.getServeStaticHandler(opts)
+}
+
+// withStaticFallbacks answers requests for paths that don't exist in the
+// public FS according to opts. Expects the path prefix to already be stripped.
+func (c *Config) withStaticFallbacks(next http.Handler, opts *StaticHandlerOptions) http.Handler {
+	if !opts.DisableDirectoryListings && opts.NotFoundFile == nil && len(opts.SPAFallbacks) == 0 {
+		return next
+	}
+
+	fallbacks := make(map[string]StaticFileRef, len(opts.SPAFallbacks))
+	fallbackPrefixes := make([]string, 0, len(opts.SPAFallbacks))
+	for prefix, ref := range opts.SPAFallbacks {
+		if prefix = strings.TrimPrefix(prefix, "/"); prefix != "" {
+			prefix = withTrailingSlash(prefix)
+		}
+		fallbacks[prefix] = ref
+		fallbackPrefixes = append(fallbackPrefixes, prefix)
+	}
+	sort.Slice(fallbackPrefixes, func(i, j int) bool {
+		return len(fallbackPrefixes[i]) > len(fallbackPrefixes[j])
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+
+		publicFS, err := c.GetPublicFS()
+		if err != nil {
+			c.Logger.Error(fmt.Sprintf("error getting public FS: %v", err))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if c.getIsServablePublicPath(publicFS, name, opts.DisableDirectoryListings) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if path.Ext(name) == "" {
+			for _, prefix := range fallbackPrefixes {
+				if strings.HasPrefix(name+"/", prefix) {
+					ref := fallbacks[prefix]
+					// Points to hashed assets, which may change with every deploy
+					w.Header().Set("Cache-Control", "no-cache")
+					if c.serveStaticFileRef(w, r, ref, http.StatusOK) {
+						return
+					}
+					w.Header().Del("Cache-Control")
+					break
+				}
+			}
+		}
+
+		if opts.NotFoundFile != nil && c.serveStaticFileRef(w, r, *opts.NotFoundFile, http.StatusNotFound) {
+			return
+		}
+
+		if opts.DisableDirectoryListings {
+			http.NotFound(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// getIsServablePublicPath reports whether the file server has something to
+// respond with for name (a directory counts only if it will be listed, or
+// has an index.html).
+func (c *Config) getIsServablePublicPath(publicFS fs.FS, name string, disableDirectoryListings bool) bool {
+	if name == "" {
+		name = "."
+	}
+	info, err := fs.Stat(publicFS, name)
+	if err != nil {
+		return false
+	}
+	if !info.IsDir() || !disableDirectoryListings {
+		return true
+	}
+	indexInfo, err := fs.Stat(publicFS, path.Join(name, "index.html"))
+	return err == nil && !indexInfo.IsDir()
+}
+
+// serveStaticFileRef writes the referenced file with the given status.
+// Returns false (having written nothing) if the file can't be read.
+func (c *Config) serveStaticFileRef(w http.ResponseWriter, r *http.Request, ref StaticFileRef, statusCode int) bool {
+	var fsys fs.FS
+	var err error
+	fsPath := cleanURL(ref.Path)
+
+	if ref.IsPrivate {
+		fsys, err = c.GetPrivateFS()
+	} else {
+		fsys, err = c.GetPublicFS()
+		if fileMapFromGob, mapErr := c.runtimeCache.publicFileMapFromGob.Get(); mapErr == nil {
+			if val, ok := fileMapFromGob[fsPath]; ok {
+				fsPath = val.Val
+			}
+		}
+	}
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error getting FS for %s: %v", ref.Path, err))
+		return false
+	}
+
+	content, err := fs.ReadFile(fsys, fsPath)
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error reading static handler fallback file %s: %v", ref.Path, err))
+		return false
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(fsPath))
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+	w.WriteHeader(statusCode)
+	if r.Method != http.MethodHead {
+		w.Write(content)
+	}
+	return true
+}
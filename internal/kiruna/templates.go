@@ -0,0 +1,124 @@
+package ik
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+type TemplatesConfig struct {
+	// Glob pattern (doublestar syntax), relative to PrivateStaticDir, of the
+	// html/template files to parse (e.g., "templates/**/*.html"). Each template
+	// is named by its path relative to PrivateStaticDir (e.g.,
+	// "templates/home.html"). Leave blank to disable the templates subsystem.
+	Glob string
+
+	// Merged over Kiruna's own helpers (see GetTemplateFuncs), so a func with
+	// the same name replaces the built-in one.
+	Funcs template.FuncMap
+}
+
+// GetTemplateFuncs returns the FuncMap templates are parsed with:
+// Kiruna's helpers (publicURL, scriptURL, criticalCSS, stylesheet,
+// publicFileMap, scripts, and refreshScript), merged with
+// Config.Templates.Funcs.
+func (c *Config) GetTemplateFuncs() template.FuncMap {
+	funcs := template.FuncMap{
+		"publicURL":     c.GetPublicURL,
+		"scriptURL":     c.GetScriptURL,
+		"criticalCSS":   c.GetCriticalCSSStyleElement,
+		"stylesheet":    c.GetStyleSheetLinkElement,
+		"publicFileMap": c.GetPublicFileMapElements,
+		"scripts":       c.GetScriptElements,
+		"refreshScript": c.GetRefreshScript,
+	}
+	for name, fn := range c.Templates.Funcs {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+func (c *Config) validateTemplatesConfig() {
+	if c.Templates.Glob != "" && !doublestar.ValidatePattern(c.Templates.Glob) {
+		panic(fmt.Sprintf("invalid glob pattern (%q) in kiruna.Config.Templates.Glob", c.Templates.Glob))
+	}
+}
+
+// parseTemplates parses every template in privateFS matching the configured
+// glob into a single set.
+func (c *Config) parseTemplates(privateFS fs.FS) (*template.Template, error) {
+	root := template.New("").Funcs(c.GetTemplateFuncs())
+	if c.Templates.Glob == "" {
+		return root, nil
+	}
+
+	matches, err := doublestar.Glob(privateFS, c.Templates.Glob, doublestar.WithFilesOnly())
+	if err != nil {
+		return nil, fmt.Errorf("error globbing templates: %v", err)
+	}
+
+	for _, match := range matches {
+		content, err := fs.ReadFile(privateFS, match)
+		if err != nil {
+			return nil, fmt.Errorf("error reading template %s: %v", match, err)
+		}
+		if _, err := root.New(path.Clean(match)).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("error parsing template %s: %w", match, err)
+		}
+	}
+
+	return root, nil
+}
+
+func (c *Config) getInitialTemplates() (*template.Template, error) {
+	privateFS, err := c.GetPrivateFS()
+	if err != nil {
+		return nil, fmt.Errorf("error getting private FS: %v", err)
+	}
+	return c.parseTemplates(privateFS)
+}
+
+// validateTemplates parses the templates just copied into the private dist
+// dir, so that syntax errors fail the build instead of the first request.
+func (c *Config) validateTemplates() error {
+	if c.Templates.Glob == "" {
+		return nil
+	}
+	baseDirFS, err := c.runtimeCache.baseDirFS.Get()
+	if err != nil {
+		return fmt.Errorf("error getting FS: %v", err)
+	}
+	// __LOCATION_ASSUMPTION: Inside "dist/kiruna"
+	privateFS, err := fs.Sub(baseDirFS, path.Join(c.__dist.S().Kiruna.S().Static.LastSegment(), PRIVATE))
+	if err != nil {
+		return fmt.Errorf("error getting private FS: %v", err)
+	}
+	_, err = c.parseTemplates(privateFS)
+	return err
+}
+
+// GetTemplates returns the parsed templates (see Config.Templates). They are
+// parsed once in prod, and on every call in dev, so edits show up on reload.
+func (c *Config) GetTemplates() (*template.Template, error) {
+	return c.runtimeCache.templates.Get()
+}
+
+// ExecuteTemplate renders the named template (e.g., "templates/home.html")
+// to w. Output is buffered, so nothing is written if execution fails.
+func (c *Config) ExecuteTemplate(w io.Writer, name string, data any) error {
+	tmpl, err := c.GetTemplates()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return fmt.Errorf("error executing template %s: %w", name, err)
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
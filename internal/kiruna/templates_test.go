@@ -0,0 +1,59 @@
+package ik
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplates(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "public-static/logo.svg", "<svg></svg>")
+	env.createTestFile(t, "private-static/templates/layout.html", `{{define "layout"}}<head>{{stylesheet}}</head><body>{{block "body" .}}{{end}}</body>{{end}}`)
+	env.createTestFile(t, "private-static/templates/home.html", `{{template "layout" .}}{{define "body"}}<img src="{{publicURL "logo.svg"}}"> {{shout .}}{{end}}`)
+	env.createTestFile(t, "private-static/emails/welcome.txt", `{{.}`)
+	env.createTestFile(t, "critical.css", "p { margin: 0; }")
+	env.createTestFile(t, "main.css", "body { margin: 0; }")
+
+	env.config.Templates = TemplatesConfig{
+		Glob:  "templates/**/*.html",
+		Funcs: template.FuncMap{"shout": strings.ToUpper},
+	}
+
+	if _, err := env.config.Build(false, false); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	var sb strings.Builder
+	if err := env.config.ExecuteTemplate(&sb, "templates/home.html", "hello"); err != nil {
+		t.Fatalf("ExecuteTemplate() error = %v", err)
+	}
+	got := sb.String()
+	for _, want := range []string{
+		string(env.config.GetStyleSheetLinkElement()),
+		`<img src="` + env.config.GetPublicURL("logo.svg") + `">`,
+		"HELLO",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ExecuteTemplate() = %q, want it to contain %q", got, want)
+		}
+	}
+
+	if err := env.config.ExecuteTemplate(&sb, "emails/welcome.txt", nil); err == nil {
+		t.Errorf("ExecuteTemplate() for a template outside the glob error = nil, want error")
+	}
+
+	// A broken template fails the build
+	brokenPath := filepath.Join(testRootDir, "private-static/templates/broken.html")
+	if err := os.WriteFile(brokenPath, []byte(`{{if}}`), 0644); err != nil {
+		t.Fatalf("failed to write broken template: %v", err)
+	}
+	_, err := env.config.Build(false, false)
+	if err == nil || !strings.Contains(err.Error(), "templates/broken.html") {
+		t.Errorf("Build() with broken template error = %v, want parse error naming the file", err)
+	}
+}
@@ -61,7 +61,7 @@ func (c *Config) getInitialBaseFS() (fs.FS, error) {
 	// DEV
 	// There is an expectation that you run the dev server from the root of your project,
 	// where your go.mod file is.
-	if GetIsDev() {
+	if c.getIsDev() {
 		if useVerboseLogs {
 			c.Logger.Info("using disk filesystem (dev)")
 		}
@@ -6,9 +6,7 @@ import (
 	"path/filepath"
 )
 
-func (c *Config) mustSetupWatcher() {
-	defer c.mustKillAppDev()
-
+func (c *Config) setupWatcher() error {
 	// Loop through all WatchedFiles...
 	for i, wfc := range c.devConfig.WatchedFiles {
 		// and make each WatchedFile's Pattern relative to cleanWatchRoot...
@@ -23,25 +21,10 @@ func (c *Config) mustSetupWatcher() {
 		}
 	}
 
-	defer c.watcher.Close()
-
-	err := c.addDirs(c.cleanWatchRoot)
-	if err != nil {
-		errMsg := fmt.Sprintf("error: failed to add directories to watcher: %v", err)
-		c.Logger.Error(errMsg)
-		panic(errMsg)
+	if err := c.addDirs(c.cleanWatchRoot); err != nil {
+		return fmt.Errorf("error: failed to add directories to watcher: %w", err)
 	}
-	done := make(chan bool)
-	go func() {
-		c.mustKillAppDev()
-		err := c.compileBinary()
-		if err != nil {
-			c.Logger.Error(fmt.Sprintf("error: failed to build app: %v", err))
-		}
-		c.mustStartAppDev()
-		c.mustHandleWatcherEmissions()
-	}()
-	<-done
+	return nil
 }
 
 func (c *Config) addDirs(path string) error {
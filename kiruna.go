@@ -1,7 +1,9 @@
 package kiruna
 
 import (
+	"context"
 	"html/template"
+	"io"
 	"io/fs"
 	"net/http"
 
@@ -19,6 +21,27 @@ type (
 	OnChange       = ik.OnChange
 	OnChangeFunc   = ik.OnChangeFunc
 	IgnorePatterns = ik.IgnorePatterns
+	DevServer      = ik.DevServer
+	BuildResult    = ik.BuildResult
+	BuildAsset     = ik.BuildAsset
+	BuildCSSBundle = ik.BuildCSSBundle
+	BuildTimings   = ik.BuildTimings
+	BuildWarning   = ik.BuildWarning
+	SizeBudget     = ik.SizeBudget
+	SizeBudgets    = ik.SizeBudgets
+	CSPOptions     = ik.CSPOptions
+	PreloadOptions = ik.PreloadOptions
+
+	StaticHandlerOptions = ik.StaticHandlerOptions
+	StaticFileRef        = ik.StaticFileRef
+	TemplatesConfig      = ik.TemplatesConfig
+	GoBuildOptions       = ik.GoBuildOptions
+	GoBuildSettings      = ik.GoBuildSettings
+	GoBuildTarget        = ik.GoBuildTarget
+	BuildGoReport        = ik.BuildGoReport
+	BuildGoBinary        = ik.BuildGoBinary
+	GoBinary             = ik.GoBinary
+	DevSidecar           = ik.DevSidecar
 )
 
 const (
@@ -26,12 +49,25 @@ const (
 	OnChangeStrategyConcurrent       = ik.OnChangeStrategyConcurrent
 	OnChangeStrategyConcurrentNoWait = ik.OnChangeStrategyConcurrentNoWait
 	OnChangeStrategyPost             = ik.OnChangeStrategyPost
+
+	BuildAssetKindPublic  = ik.BuildAssetKindPublic
+	BuildAssetKindPrivate = ik.BuildAssetKindPrivate
+	BuildAssetKindScript  = ik.BuildAssetKindScript
+	BuildAssetKindCSS     = ik.BuildAssetKindCSS
+
+	GoBinaryRestartOnChange = ik.GoBinaryRestartOnChange
+	GoBinaryRestartAlways   = ik.GoBinaryRestartAlways
+	GoBinaryRestartNever    = ik.GoBinaryRestartNever
 )
 
 var (
-	MustGetPort  = ik.MustGetPort
-	GetIsDev     = ik.GetIsDev
+	// Deprecated: Use Kiruna.MustGetPort instead.
+	MustGetPort = ik.MustGetPort
+	// Deprecated: Use Kiruna.SetModeToDev instead.
 	SetModeToDev = ik.SetModeToDev
+
+	GetIsDev    = ik.GetIsDev
+	GetCSPNonce = ik.GetCSPNonce
 )
 
 func New(c *ik.Config) *Kiruna {
@@ -48,9 +84,20 @@ func New(c *ik.Config) *Kiruna {
 // and then you can control your build yourself afterwards.
 
 func (k Kiruna) Build() error {
-	return k.c.Build(true, false)
+	_, err := k.c.Build(true, false)
+	return err
 }
 func (k Kiruna) BuildWithoutCompilingGo() error {
+	_, err := k.c.Build(false, false)
+	return err
+}
+
+// BuildWithResult is like Build, but also returns a description of everything
+// the build produced. The same report is written to dist/kiruna/build_report.json.
+func (k Kiruna) BuildWithResult() (*BuildResult, error) {
+	return k.c.Build(true, false)
+}
+func (k Kiruna) BuildWithoutCompilingGoWithResult() (*BuildResult, error) {
 	return k.c.Build(false, false)
 }
 
@@ -74,24 +121,68 @@ func (k Kiruna) MustGetPrivateFS() fs.FS {
 	}
 	return fs
 }
+func (k Kiruna) GetContentSecurityPolicy(opts *CSPOptions, nonce string) string {
+	return k.c.GetContentSecurityPolicy(opts, nonce)
+}
+func (k Kiruna) CSPMiddleware(opts *CSPOptions) func(http.Handler) http.Handler {
+	return k.c.CSPMiddleware(opts)
+}
+func (k Kiruna) NonceMiddleware(next http.Handler) http.Handler {
+	return k.c.NonceMiddleware(next)
+}
+func (k Kiruna) GetPreloadLinks(originalPublicURLs ...string) []string {
+	return k.c.GetPreloadLinks(originalPublicURLs...)
+}
+func (k Kiruna) PreloadMiddleware(opts *PreloadOptions) func(http.Handler) http.Handler {
+	return k.c.PreloadMiddleware(opts)
+}
+func (k Kiruna) GetPublicAssetIntegrity(originalPublicURL string) string {
+	return k.c.GetPublicAssetIntegrity(originalPublicURL)
+}
 func (k Kiruna) GetPublicURL(originalPublicURL string) string {
 	return k.c.GetPublicURL(originalPublicURL)
 }
 func (k Kiruna) MustGetPublicURLBuildtime(originalPublicURL string) string {
 	return k.c.MustGetPublicURLBuildtime(originalPublicURL)
 }
+
+// SetModeToDev puts this Kiruna instance into dev mode, without affecting
+// any other instance in the process.
+func (k Kiruna) SetModeToDev() {
+	k.c.SetModeToDev()
+}
+
+// MustGetPort returns the port your app should listen on (see MustGetPort).
+func (k Kiruna) MustGetPort() int {
+	return k.c.MustGetPort()
+}
 func (k Kiruna) MustStartDev(devConfig *DevConfig) {
 	k.c.MustStartDev(devConfig)
 }
+
+// StartDev starts the dev loop and returns once it is up and running.
+// Cancel ctx or call DevServer.Stop to shut it down.
+func (k Kiruna) StartDev(ctx context.Context, devConfig *DevConfig) (*DevServer, error) {
+	return k.c.StartDev(ctx, devConfig)
+}
 func (k Kiruna) GetCriticalCSS() template.CSS {
 	return template.CSS(k.c.GetCriticalCSS())
 }
 func (k Kiruna) GetStyleSheetURL() string {
 	return k.c.GetStyleSheetURL()
 }
+func (k Kiruna) GetScriptURL(name string) string {
+	return k.c.GetScriptURL(name)
+}
+func (k Kiruna) GetScriptElements() template.HTML {
+	return k.c.GetScriptElements()
+}
 func (k Kiruna) GetRefreshScript() template.HTML {
 	return template.HTML(k.c.GetRefreshScript())
 }
+func (k Kiruna) GetRefreshScriptWithNonce(nonce string) template.HTML {
+	return k.c.GetRefreshScriptWithNonce(nonce)
+}
 func (k Kiruna) GetRefreshScriptSha256Hash() string {
 	return k.c.GetRefreshScriptSha256Hash()
 }
@@ -107,12 +198,18 @@ func (k Kiruna) GetBaseFS() (fs.FS, error) {
 func (k Kiruna) GetCriticalCSSStyleElement() template.HTML {
 	return k.c.GetCriticalCSSStyleElement()
 }
+func (k Kiruna) GetCriticalCSSStyleElementWithNonce(nonce string) template.HTML {
+	return k.c.GetCriticalCSSStyleElementWithNonce(nonce)
+}
 func (k Kiruna) GetCriticalCSSStyleElementSha256Hash() string {
 	return k.c.GetCriticalCSSStyleElementSha256Hash()
 }
 func (k Kiruna) GetStyleSheetLinkElement() template.HTML {
 	return k.c.GetStyleSheetLinkElement()
 }
+func (k Kiruna) GetPublicPathPrefix() string {
+	return k.c.GetPublicPathPrefix()
+}
 func (k Kiruna) GetServeStaticHandler(pathPrefix string, addImmutableCacheHeaders bool) (http.Handler, error) {
 	return k.c.GetServeStaticHandler(pathPrefix, addImmutableCacheHeaders)
 }
@@ -123,6 +220,35 @@ func (k Kiruna) MustGetServeStaticHandler(pathPrefix string, addImmutableCacheHe
 	}
 	return handler
 }
+func (k Kiruna) GetServeStaticHandlerWithOptions(opts *StaticHandlerOptions) (http.Handler, error) {
+	return k.c.GetServeStaticHandlerWithOptions(opts)
+}
+func (k Kiruna) MustGetServeStaticHandlerWithOptions(opts *StaticHandlerOptions) http.Handler {
+	handler, err := k.c.GetServeStaticHandlerWithOptions(opts)
+	if err != nil {
+		panic(err)
+	}
+	return handler
+}
+func (k Kiruna) OnPrivateFilesChangeDev(fn func()) {
+	k.c.OnPrivateFilesChangeDev(fn)
+}
+func (k Kiruna) GetTemplates() (*template.Template, error) {
+	return k.c.GetTemplates()
+}
+func (k Kiruna) MustGetTemplates() *template.Template {
+	tmpl, err := k.c.GetTemplates()
+	if err != nil {
+		panic(err)
+	}
+	return tmpl
+}
+func (k Kiruna) GetTemplateFuncs() template.FuncMap {
+	return k.c.GetTemplateFuncs()
+}
+func (k Kiruna) ExecuteTemplate(w io.Writer, name string, data any) error {
+	return k.c.ExecuteTemplate(w, name, data)
+}
 func (k Kiruna) GetPublicFileMap() (FileMap, error) {
 	return k.c.GetPublicFileMap()
 }
@@ -132,6 +258,9 @@ func (k Kiruna) GetPublicFileMapKeysBuildtime() ([]string, error) {
 func (k Kiruna) GetPublicFileMapElements() template.HTML {
 	return k.c.GetPublicFileMapElements()
 }
+func (k Kiruna) GetPublicFileMapElementsWithNonce(nonce string) template.HTML {
+	return k.c.GetPublicFileMapElementsWithNonce(nonce)
+}
 func (k Kiruna) GetPublicFileMapScriptSha256Hash() string {
 	return k.c.GetPublicFileMapScriptSha256Hash()
 }